@@ -1,8 +1,12 @@
 package ipacl
 
 import (
+	"cmp"
+	"math"
+	"net"
 	"net/netip"
 	"strings"
+	"unsafe"
 )
 
 const debug = false
@@ -15,9 +19,17 @@ type BinarySearch struct {
 
 	v6EndAddrs        []v6Addr
 	v6EvenIndexIsDeny bool
+
+	// v6EndHis is the high 64 bits of the IPv6 end addresses in compact mode,
+	// which is used when the low 64 bits of all end addresses are all ones,
+	// that is, when all boundaries fall on /64 edges. v6EndAddrs is nil in
+	// compact mode.
+	v6EndHis []uint64
 }
 
 // NewBinarySearch creates an BinarySearch instance.
+// It panics if rules contain a host rule, which must be resolved with
+// ResolveRules or NewResolvingACL beforehand.
 func NewBinarySearch(rules []Rule) BinarySearch {
 	b := newBinarySearchBuilder(rules)
 	return b.toBinarySearch()
@@ -26,27 +38,101 @@ func NewBinarySearch(rules []Rule) BinarySearch {
 // Lookup lookups an IP address and returns the action defined in the access control list.
 func (s *BinarySearch) Lookup(ip netip.Addr) Action {
 	if ip.Is4() {
-		target := v4AddrFromBytes(ip.As4())
-		if len(s.v4EndAddrs) > 0 {
-			i, _ := binarySearchNoDupFunc(s.v4EndAddrs, target, func(e, t v4Addr) int {
-				return e.Compare(t)
-			})
-			if s.isDenyIndexV4(i) {
-				return Deny
+		return s.lookupV4(v4AddrFromBytes(ip.As4()))
+	}
+	return s.lookupV6(v6AddrFromBytes(ip.As16()))
+}
+
+// LookupAddrPort lookups the address of an address and port pair.
+func (s *BinarySearch) LookupAddrPort(ap netip.AddrPort) Action {
+	return s.Lookup(ap.Addr())
+}
+
+// LookupNetIP lookups a net.IP address. Unlike Lookup, an IPv4-mapped IPv6
+// address is looked up as an IPv4 address, in the same way as net.IP treats it.
+// If ip is neither 4 nor 16 bytes long, LookupNetIP returns Deny.
+func (s *BinarySearch) LookupNetIP(ip net.IP) Action {
+	if ip4 := ip.To4(); ip4 != nil {
+		return s.lookupV4(v4AddrFromBytes([4]byte(ip4)))
+	}
+	if len(ip) == net.IPv6len {
+		return s.lookupV6(v6AddrFromBytes([16]byte(ip)))
+	}
+	return Deny
+}
+
+// Lookup4 lookups an IPv4 address in the 4-byte representation.
+func (s *BinarySearch) Lookup4(a [4]byte) Action {
+	return s.lookupV4(v4AddrFromBytes(a))
+}
+
+// Lookup16 lookups an IPv6 address in the 16-byte representation.
+// An IPv4-mapped IPv6 address is looked up as an IPv6 address, in the same way
+// as Lookup does.
+func (s *BinarySearch) Lookup16(a [16]byte) Action {
+	return s.lookupV6(v6AddrFromBytes(a))
+}
+
+// LookupString parses an IP address in the same syntax as netip.ParseAddr
+// and lookups it without allocation. The zone of an IPv6 address is ignored.
+// It returns false if s is not a valid IP address.
+func (s *BinarySearch) LookupString(str string) (Action, bool) {
+	for i := 0; i < len(str); i++ {
+		switch str[i] {
+		case '.':
+			a, ok := scanV4Addr(str)
+			if !ok {
+				return Deny, false
 			}
+			return s.lookupV4(a), true
+		case ':':
+			a, ok := scanV6Addr(str)
+			if !ok {
+				return Deny, false
+			}
+			return s.lookupV6(a), true
+		case '%':
+			// An IPv4 address with a zone or a zone without an address.
+			return Deny, false
 		}
-		return Allow
 	}
+	return Deny, false
+}
 
-	target := v6AddrFromBytes(ip.As16())
-	if len(s.v6EndAddrs) > 0 {
-		i, _ := binarySearchNoDupFunc(s.v6EndAddrs, target, func(e, t v6Addr) int {
-			return e.Compare(t)
-		})
-		if s.isDenyIndexV6(i) {
-			return Deny
+func (s *BinarySearch) lookupV4(target v4Addr) Action {
+	return lookupEndAddrs(len(s.v4EndAddrs), func(i int) v4Addr { return s.v4EndAddrs[i] },
+		target, v4Addr.Compare, s.v4EvenIndexIsDeny)
+}
+
+func (s *BinarySearch) lookupV6(target v6Addr) Action {
+	if len(s.v6EndHis) > 0 {
+		return lookupEndAddrs(len(s.v6EndHis), func(i int) uint64 { return s.v6EndHis[i] },
+			target.hi, cmp.Compare[uint64], s.v6EvenIndexIsDeny)
+	}
+	return lookupEndAddrs(len(s.v6EndAddrs), func(i int) v6Addr { return s.v6EndAddrs[i] },
+		target, v6Addr.Compare, s.v6EvenIndexIsDeny)
+}
+
+// lookupEndAddrs returns the action for target in n end addresses sorted in
+// increasing order, where endAddr returns the end address of index i.
+// It returns Allow if n is zero.
+func lookupEndAddrs[A any](n int, endAddr func(i int) A, target A, compare func(a, b A) int, evenIndexIsDeny bool) Action {
+	if n == 0 {
+		return Allow
+	}
+	// Find the first end address which is not before target.
+	i, j := 0, n
+	for i < j {
+		h := int(uint(i+j) >> 1) // avoid overflow when computing h
+		if compare(endAddr(h), target) < 0 {
+			i = h + 1
+		} else {
+			j = h
 		}
 	}
+	if isDenyIndex(i, evenIndexIsDeny) {
+		return Deny
+	}
 	return Allow
 }
 
@@ -83,20 +169,118 @@ func binarySearchNoDupFunc[S ~[]E, E, T any](x S, target T, cmp func(E, T) int)
 	return i, false
 }
 
-func (s *BinarySearch) isDenyIndexV4(i int) bool {
-	if s.v4EvenIndexIsDeny {
-		return i%2 == 0
+// MemoryUsage returns the number of bytes used by the end addresses
+// for each address family.
+func (s *BinarySearch) MemoryUsage() (v4, v6 int) {
+	v4 = cap(s.v4EndAddrs) * int(unsafe.Sizeof(v4Addr(0)))
+	v6 = cap(s.v6EndAddrs)*int(unsafe.Sizeof(v6Addr{})) + cap(s.v6EndHis)*int(unsafe.Sizeof(uint64(0)))
+	return v4, v6
+}
+
+// compactV6 switches the IPv6 end addresses to compact mode if possible.
+func (s *BinarySearch) compactV6() {
+	if len(s.v6EndAddrs) == 0 {
+		return
 	}
-	return i%2 == 1
+	for _, a := range s.v6EndAddrs {
+		if a.lo != math.MaxUint64 {
+			return
+		}
+	}
+	s.v6EndHis = make([]uint64, len(s.v6EndAddrs))
+	for i, a := range s.v6EndAddrs {
+		s.v6EndHis[i] = a.hi
+	}
+	s.v6EndAddrs = nil
+}
+
+// v6Ends returns the IPv6 end addresses regardless of compact mode.
+// The returned slice must not be modified.
+func (s *BinarySearch) v6Ends() []v6Addr {
+	if s.v6EndHis == nil {
+		return s.v6EndAddrs
+	}
+	ends := make([]v6Addr, len(s.v6EndHis))
+	for i, hi := range s.v6EndHis {
+		ends[i] = v6Addr{hi: hi, lo: math.MaxUint64}
+	}
+	return ends
+}
+
+func (s *BinarySearch) isDenyIndexV4(i int) bool {
+	return isDenyIndex(i, s.v4EvenIndexIsDeny)
 }
 
 func (s *BinarySearch) isDenyIndexV6(i int) bool {
-	if s.v6EvenIndexIsDeny {
+	return isDenyIndex(i, s.v6EvenIndexIsDeny)
+}
+
+// isDenyIndex returns whether addresses of index i in end addresses
+// are denied.
+func isDenyIndex(i int, evenIndexIsDeny bool) bool {
+	if evenIndexIsDeny {
 		return i%2 == 0
 	}
 	return i%2 == 1
 }
 
+func (s *BinarySearch) actionForIndexV4(i int) Action {
+	if s.isDenyIndexV4(i) {
+		return Deny
+	}
+	return Allow
+}
+
+func (s *BinarySearch) actionForIndexV6(i int) Action {
+	if s.isDenyIndexV6(i) {
+		return Deny
+	}
+	return Allow
+}
+
+// v4RuleRanges returns the ranges which cover the whole IPv4 address space
+// with the action of each range, in the same way as Lookup.
+func (s *BinarySearch) v4RuleRanges() []ruleRangeV4 {
+	ranges := make([]ruleRangeV4, 0, len(s.v4EndAddrs)+1)
+	var start v4Addr
+	for i, end := range s.v4EndAddrs {
+		ranges = append(ranges, ruleRangeV4{
+			ipRange: v4Range{start: start, end: end},
+			action:  s.actionForIndexV4(i),
+		})
+		start = end.Next()
+	}
+	if n := len(ranges); n == 0 || !ranges[n-1].ipRange.end.IsLast() {
+		ranges = append(ranges, ruleRangeV4{
+			ipRange: v4Range{start: start, end: lastV4Addr},
+			action:  s.actionForIndexV4(n),
+		})
+	}
+	return ranges
+}
+
+// v6RuleRanges returns the ranges which cover the whole IPv6 address space
+// with the action of each range, in the same way as Lookup.
+func (s *BinarySearch) v6RuleRanges() []ruleRangeV6 {
+	ends := s.v6Ends()
+	ranges := make([]ruleRangeV6, 0, len(ends)+1)
+	var start v6Addr
+	for i, end := range ends {
+		ranges = append(ranges, ruleRangeV6{
+			ipRange: v6Range{start: start, end: end},
+			action:  s.actionForIndexV6(i),
+		})
+		start = end.Next()
+	}
+	if n := len(ranges); n == 0 || !ranges[n-1].ipRange.end.IsLast() {
+		ranges = append(ranges, ruleRangeV6{
+			ipRange: v6Range{start: start, end: lastV6Addr},
+			action:  s.actionForIndexV6(n),
+		})
+	}
+	return ranges
+}
+
 func (s *BinarySearch) String() string {
 	var b strings.Builder
 	b.WriteString("BinarySearch{v4:[")
@@ -118,7 +302,8 @@ func (s *BinarySearch) String() string {
 		}
 	}
 	b.WriteString("], v6:[")
-	for i := range s.v6EndAddrs {
+	v6EndAddrs := s.v6Ends()
+	for i := range v6EndAddrs {
 		if i > 0 {
 			b.WriteString(", ")
 		}
@@ -127,12 +312,12 @@ func (s *BinarySearch) String() string {
 		}
 		var startAddr v6Addr
 		if i > 0 {
-			startAddr = s.v6EndAddrs[i-1].Next()
+			startAddr = v6EndAddrs[i-1].Next()
 		}
 		b.WriteString(startAddr.String())
-		if s.v6EndAddrs[i].Compare(startAddr) != 0 {
+		if v6EndAddrs[i].Compare(startAddr) != 0 {
 			b.WriteByte('-')
-			b.WriteString(s.v6EndAddrs[i].String())
+			b.WriteString(v6EndAddrs[i].String())
 		}
 	}
 	b.WriteString("]}")
@@ -170,11 +355,15 @@ func (b *binarySearchBuilder) toBinarySearch() BinarySearch {
 		}
 		s.v6EndAddrs[i] = r.ipRange.end
 	}
+	s.compactV6()
 
 	return s
 }
 
 func (b *binarySearchBuilder) insertRule(rule Rule) {
+	if rule.host != "" {
+		panic("ipacl: unresolved host rule: " + rule.String())
+	}
 	if rule.target.Addr().Is4() {
 		v4Rule := ruleRangeV4FromCIDR(rule)
 		b.v4Rules = ruleRangeV4ListAddRange(b.v4Rules, v4Rule)
@@ -2,6 +2,7 @@ package ipacl
 
 import (
 	"cmp"
+	"net"
 	"net/netip"
 	"slices"
 	"strings"
@@ -105,6 +106,15 @@ func TestBinarySearch_Lookup(t *testing.T) {
 	}
 }
 
+func TestNewBinarySearch_hostRule(t *testing.T) {
+	defer func() {
+		if got, want := recover(), "ipacl: unresolved host rule: deny host:db.internal"; got != want {
+			t.Errorf("panic mismatch, got=%v, want=%s", got, want)
+		}
+	}()
+	NewBinarySearch([]Rule{NewHostRule("db.internal", Deny), NewRule(allIPv4CIDR, Allow)})
+}
+
 func TestBinarySearch_String(t *testing.T) {
 	testCases := []struct {
 		rules string
@@ -141,6 +151,71 @@ func TestBinarySearch_String(t *testing.T) {
 	}
 }
 
+func TestBinarySearch_compactV6(t *testing.T) {
+	testCases := []struct {
+		rules       string
+		wantCompact bool
+		inputs      []string
+	}{
+		{
+			rules:       "deny 2001:db8::/32\nallow 2001:db8:1::/48\ndeny 2001:db8:1:2::/64",
+			wantCompact: true,
+			inputs: []string{"::", "2001:db7:ffff:ffff:ffff:ffff:ffff:ffff", "2001:db8::", "2001:db8:1::",
+				"2001:db8:1:1:ffff:ffff:ffff:ffff", "2001:db8:1:2::", "2001:db8:1:2:ffff:ffff:ffff:ffff",
+				"2001:db8:1:3::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", "2001:db9::"},
+		},
+		{
+			rules:       "allow 2001:db8::1/128\ndeny 2001:db8::/32",
+			wantCompact: false,
+			inputs:      []string{"2001:db8::", "2001:db8::1", "2001:db8::2", "2001:db9::"},
+		},
+		{
+			rules:       "deny all",
+			wantCompact: true,
+			inputs:      []string{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+		},
+	}
+	for i, tc := range testCases {
+		rules, err := ParseRuleLines(tc.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs := NewBinarySearch(rules)
+		if got, want := bs.v6EndHis != nil, tc.wantCompact; got != want {
+			t.Errorf("compact mode mismatch, rules=%d, got=%v, want=%v", i, got, want)
+		}
+		ls := newLinearSearch(rules)
+		for _, input := range tc.inputs {
+			target := netip.MustParseAddr(input)
+			if got, want := bs.Lookup(target), ls.Lookup(target); got != want {
+				t.Errorf("result mismatch, rules=%d, input=%s, got=%s, want=%s", i, input, got, want)
+			}
+		}
+	}
+}
+
+func TestBinarySearch_MemoryUsage(t *testing.T) {
+	testCases := []struct {
+		rules  string
+		wantV4 int
+		wantV6 int
+	}{
+		{rules: "deny 192.0.2.0/24\ndeny 2001:db8::/32", wantV4: 3 * 4, wantV6: 3 * 8},
+		{rules: "deny 192.0.2.1\ndeny 2001:db8::1/128", wantV4: 3 * 4, wantV6: 3 * 16},
+	}
+	for i, tc := range testCases {
+		rules, err := ParseRuleLines(tc.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs := NewBinarySearch(rules)
+		gotV4, gotV6 := bs.MemoryUsage()
+		if gotV4 != tc.wantV4 || gotV6 != tc.wantV6 {
+			t.Errorf("result mismatch, rules=%d, got=(%d, %d), want=(%d, %d)", i, gotV4, gotV6, tc.wantV4, tc.wantV6)
+		}
+	}
+}
+
 func FuzzBinarySearch_Lookup(f *testing.F) {
 	f.Add(`
 		deny  192.168.1.1
@@ -158,7 +233,7 @@ func FuzzBinarySearch_Lookup(f *testing.F) {
 		`, "2001:0db8::")
 	f.Fuzz(func(t *testing.T, s, input string) {
 		rules, err := ParseRuleLines(s)
-		if err != nil {
+		if err != nil || hasHostRule(rules) {
 			t.Skip()
 		}
 		target, err := netip.ParseAddr(input)
@@ -175,6 +250,143 @@ func FuzzBinarySearch_Lookup(f *testing.F) {
 	})
 }
 
+func TestBinarySearch_lookupEntryPoints(t *testing.T) {
+	rules, err := ParseRuleLines(`
+		deny  192.0.2.1
+		allow 192.0.2.0/24
+		deny  2001:db8::1/128
+		allow 2001:db8::/32
+		allow ::ffff:198.51.100.0/120
+		deny  all
+		`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := NewBinarySearch(rules)
+
+	for _, input := range []string{"192.0.2.1", "192.0.2.2", "198.51.100.1", "2001:db8::1", "2001:db8::2", "2001:db9::", "::ffff:198.51.100.1", "fe80::1%eth0"} {
+		ip := netip.MustParseAddr(input)
+		want := bs.Lookup(ip)
+		if got := bs.LookupAddrPort(netip.AddrPortFrom(ip, 443)); got != want {
+			t.Errorf("LookupAddrPort result mismatch, input=%s, got=%s, want=%s", input, got, want)
+		}
+		if got, ok := bs.LookupString(input); !ok || got != want {
+			t.Errorf("LookupString result mismatch, input=%s, got=(%s, %v), want=(%s, true)", input, got, ok, want)
+		}
+		if ip.Is4() {
+			if got := bs.Lookup4(ip.As4()); got != want {
+				t.Errorf("Lookup4 result mismatch, input=%s, got=%s, want=%s", input, got, want)
+			}
+		} else {
+			if got := bs.Lookup16(ip.As16()); got != want {
+				t.Errorf("Lookup16 result mismatch, input=%s, got=%s, want=%s", input, got, want)
+			}
+		}
+	}
+
+	// LookupNetIP looks up an IPv4-mapped IPv6 address as an IPv4 address.
+	netIPCases := []struct {
+		input net.IP
+		want  Action
+	}{
+		{input: net.ParseIP("192.0.2.1"), want: Deny},
+		{input: net.ParseIP("192.0.2.2"), want: Allow},
+		{input: net.ParseIP("192.0.2.2").To4(), want: Allow},
+		{input: net.ParseIP("198.51.100.1"), want: Deny},
+		{input: net.ParseIP("2001:db8::1"), want: Deny},
+		{input: net.ParseIP("2001:db8::2"), want: Allow},
+		{input: nil, want: Deny},
+		{input: net.IP{192, 0, 2}, want: Deny},
+	}
+	for _, tc := range netIPCases {
+		if got := bs.LookupNetIP(tc.input); got != tc.want {
+			t.Errorf("LookupNetIP result mismatch, input=%v, got=%s, want=%s", tc.input, got, tc.want)
+		}
+	}
+
+	for _, input := range []string{"", "192.0.2", "192.0.2.1%eth0", "%eth0", "2001:db8::1%", "example.com"} {
+		if _, ok := bs.LookupString(input); ok {
+			t.Errorf("LookupString want not ok, input=%s", input)
+		}
+	}
+}
+
+func TestBinarySearch_lookupAllocs(t *testing.T) {
+	rules, err := ParseRuleLines(`
+		deny  192.0.2.1
+		allow 192.0.2.0/24
+		deny  2001:db8::1/128
+		allow 2001:db8::/32
+		deny  all
+		`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compactRules, err := ParseRuleLines("allow 2001:db8::/32\ndeny all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := NewBinarySearch(rules)
+	compact := NewBinarySearch(compactRules)
+
+	v4 := netip.MustParseAddr("192.0.2.2")
+	v6 := netip.MustParseAddr("2001:db8::2")
+	netIP4 := net.ParseIP("192.0.2.2")
+	netIP6 := net.ParseIP("2001:db8::2")
+	testCases := []struct {
+		name string
+		f    func()
+	}{
+		{name: "Lookup", f: func() { bs.Lookup(v4); bs.Lookup(v6); compact.Lookup(v6) }},
+		{name: "LookupAddrPort", f: func() { bs.LookupAddrPort(netip.AddrPortFrom(v6, 443)) }},
+		{name: "LookupNetIP", f: func() { bs.LookupNetIP(netIP4); bs.LookupNetIP(netIP6) }},
+		{name: "Lookup4", f: func() { bs.Lookup4(v4.As4()) }},
+		{name: "Lookup16", f: func() { bs.Lookup16(v6.As16()) }},
+		{name: "LookupString", f: func() {
+			bs.LookupString("192.0.2.2")
+			bs.LookupString("2001:db8::2")
+			bs.LookupString("::ffff:192.0.2.2")
+			bs.LookupString("fe80::1%eth0")
+			bs.LookupString("not an address")
+		}},
+	}
+	for _, tc := range testCases {
+		if got := testing.AllocsPerRun(100, tc.f); got != 0 {
+			t.Errorf("allocation count mismatch, name=%s, got=%v, want=0", tc.name, got)
+		}
+	}
+}
+
+func FuzzBinarySearch_LookupString(f *testing.F) {
+	for _, input := range []string{"192.0.2.1", "2001:db8::1", "::ffff:192.0.2.1", "fe80::1%eth0", "1::2::3", "192.0.2.01"} {
+		f.Add(input)
+	}
+	rules, err := ParseRuleLines(`
+		deny  192.0.2.1
+		allow 192.0.2.0/24
+		deny  2001:db8::1/128
+		allow 2001:db8::/32
+		deny  all
+		`)
+	if err != nil {
+		f.Fatal(err)
+	}
+	bs := NewBinarySearch(rules)
+	f.Fuzz(func(t *testing.T, input string) {
+		got, ok := bs.LookupString(input)
+		ip, err := netip.ParseAddr(input)
+		if ok != (err == nil) {
+			t.Fatalf("ok mismatch, input=%q, got=%v, err=%v", input, ok, err)
+		}
+		if !ok {
+			return
+		}
+		if want := bs.Lookup(ip); got != want {
+			t.Errorf("result mismatch, input=%q, got=%s, want=%s", input, got, want)
+		}
+	})
+}
+
 func TestSlicesBinarySearchFunc(t *testing.T) {
 	s := []int{1, 1, 2}
 	target := 1
@@ -0,0 +1,90 @@
+// Command ipaclfmt formats access control list files in the canonical format.
+//
+// Usage:
+//
+//	ipaclfmt [-w] [-check] [path ...]
+//
+// Without paths, ipaclfmt formats the standard input and writes the result
+// to the standard output. With -w, the results are written back to the files.
+// With -check, ipaclfmt writes the names of files which are not formatted
+// and exits with status 1 if there are any.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("ipaclfmt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	write := fs.Bool("w", false, "write result to source file instead of stdout")
+	check := fs.Bool("check", false, "list files which are not formatted and exit with status 1 if any")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "ipaclfmt: cannot use -w with standard input")
+			return 2
+		}
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "ipaclfmt: %s\n", err)
+			return 2
+		}
+		return processFile("<standard input>", src, stdout, stderr, false, *check)
+	}
+
+	exitCode := 0
+	for _, path := range fs.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "ipaclfmt: %s\n", err)
+			exitCode = 2
+			continue
+		}
+		exitCode = max(exitCode, processFile(path, src, stdout, stderr, *write, *check))
+	}
+	return exitCode
+}
+
+func processFile(path string, src []byte, stdout, stderr io.Writer, write, check bool) int {
+	formatted, err := ipacl.Format(src)
+	if err != nil {
+		fmt.Fprintf(stderr, "ipaclfmt: %s: %s\n", path, err)
+		return 2
+	}
+	if check {
+		if !bytes.Equal(src, formatted) {
+			fmt.Fprintln(stdout, path)
+			return 1
+		}
+		return 0
+	}
+	if write {
+		if bytes.Equal(src, formatted) {
+			return 0
+		}
+		if err := os.WriteFile(path, formatted, 0o644); err != nil {
+			fmt.Fprintf(stderr, "ipaclfmt: %s\n", err)
+			return 2
+		}
+		return 0
+	}
+	if _, err := stdout.Write(formatted); err != nil {
+		fmt.Fprintf(stderr, "ipaclfmt: %s\n", err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	const unformatted = "allow 192.0.2.1/24 # docs\ndeny all\n"
+	const formatted = "allow 192.0.2.0/24 # docs\ndeny  all\n"
+
+	t.Run("stdin", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if got, want := run(nil, strings.NewReader(unformatted), &stdout, &stderr), 0; got != want {
+			t.Fatalf("exit code mismatch, got=%d, want=%d, stderr=%s", got, want, stderr.String())
+		}
+		if got, want := stdout.String(), formatted; got != want {
+			t.Errorf("output mismatch, got=%q, want=%q", got, want)
+		}
+	})
+	t.Run("check", func(t *testing.T) {
+		dir := t.TempDir()
+		good := filepath.Join(dir, "good.acl")
+		bad := filepath.Join(dir, "bad.acl")
+		if err := os.WriteFile(good, []byte(formatted), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(bad, []byte(unformatted), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var stdout, stderr bytes.Buffer
+		if got, want := run([]string{"-check", good, bad}, nil, &stdout, &stderr), 1; got != want {
+			t.Fatalf("exit code mismatch, got=%d, want=%d, stderr=%s", got, want, stderr.String())
+		}
+		if got, want := stdout.String(), bad+"\n"; got != want {
+			t.Errorf("output mismatch, got=%q, want=%q", got, want)
+		}
+	})
+	t.Run("write", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "acl")
+		if err := os.WriteFile(path, []byte(unformatted), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var stdout, stderr bytes.Buffer
+		if got, want := run([]string{"-w", path}, nil, &stdout, &stderr), 0; got != want {
+			t.Fatalf("exit code mismatch, got=%d, want=%d, stderr=%s", got, want, stderr.String())
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != formatted {
+			t.Errorf("file content mismatch, got=%q, want=%q", got, formatted)
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if got, want := run(nil, strings.NewReader("allow"), &stdout, &stderr), 2; got != want {
+			t.Fatalf("exit code mismatch, got=%d, want=%d", got, want)
+		}
+		if got, want := stderr.String(), "ipaclfmt: <standard input>: two fields must exist at line 1\n"; got != want {
+			t.Errorf("error mismatch, got=%q, want=%q", got, want)
+		}
+	})
+	t.Run("check invalid", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "acl")
+		const invalid = "Allow 192.0.2.0/24\n"
+		if err := os.WriteFile(path, []byte(invalid), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var stdout, stderr bytes.Buffer
+		if got, want := run([]string{"-check", "-w", path}, nil, &stdout, &stderr), 2; got != want {
+			t.Fatalf("exit code mismatch, got=%d, want=%d, stderr=%s", got, want, stderr.String())
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != invalid {
+			t.Errorf("invalid file is rewritten, got=%q, want=%q", got, invalid)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+// Command ipaclgen compiles an access control list file into Go source code.
+//
+// Usage:
+//
+//	ipaclgen [-i input] [-o output] [-pkg package] [-func name]
+//
+// Without -i, ipaclgen reads the standard input. Without -o, it writes the
+// result to the standard output. The package name defaults to $GOPACKAGE,
+// so ipaclgen can be used in a go:generate directive like:
+//
+//	//go:generate ipaclgen -i acl.txt -o acl_gen.go -func lookupACL
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("ipaclgen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	input := fs.String("i", "", "input access control list file (default standard input)")
+	output := fs.String("o", "", "output Go file (default standard output)")
+	pkg := fs.String("pkg", os.Getenv("GOPACKAGE"), "package name of the generated file (default $GOPACKAGE)")
+	funcName := fs.String("func", "Lookup", "name of the generated lookup function")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "ipaclgen: unexpected arguments: %q\n", fs.Args())
+		return 2
+	}
+	if *pkg == "" {
+		fmt.Fprintln(stderr, "ipaclgen: package name must be specified with -pkg or $GOPACKAGE")
+		return 2
+	}
+
+	var src []byte
+	var err error
+	source := ""
+	if *input == "" {
+		src, err = io.ReadAll(stdin)
+	} else {
+		src, err = os.ReadFile(*input)
+		source = filepath.Base(*input)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "ipaclgen: %s\n", err)
+		return 2
+	}
+	rules, err := ipacl.ParseRuleLines(string(src))
+	if err != nil {
+		fmt.Fprintf(stderr, "ipaclgen: %s\n", err)
+		return 2
+	}
+	for _, rule := range rules {
+		if rule.Host() != "" {
+			fmt.Fprintf(stderr, "ipaclgen: host rules are not supported: %s\n", rule)
+			return 2
+		}
+	}
+	s := ipacl.NewBinarySearch(rules)
+
+	var buf bytes.Buffer
+	if err := ipacl.GenerateGo(&buf, &s, ipacl.GenerateOptions{
+		Package:  *pkg,
+		FuncName: *funcName,
+		Source:   source,
+	}); err != nil {
+		fmt.Fprintf(stderr, "ipaclgen: %s\n", err)
+		return 2
+	}
+
+	if *output == "" {
+		_, err = stdout.Write(buf.Bytes())
+	} else {
+		err = os.WriteFile(*output, buf.Bytes(), 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "ipaclgen: %s\n", err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	const acl = "deny 192.0.2.1\nallow 2001:db8::/32\ndeny all\n"
+
+	t.Run("stdin", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if got, want := run([]string{"-pkg", "acl", "-func", "lookupACL"}, strings.NewReader(acl), &stdout, &stderr), 0; got != want {
+			t.Fatalf("exit code mismatch, got=%d, want=%d, stderr=%s", got, want, stderr.String())
+		}
+		out := stdout.String()
+		for _, want := range []string{
+			"// Code generated by ipaclgen. DO NOT EDIT.\n",
+			"package acl\n",
+			"func lookupACL(ip netip.Addr) ipacl.Action {\n",
+			"0x20010db8ffffffff, 0xffffffffffffffff}, // 2001:db8:ffff:ffff:ffff:ffff:ffff:ffff\n",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("output does not contain %q, got=%s", want, out)
+			}
+		}
+	})
+	t.Run("file", func(t *testing.T) {
+		t.Setenv("GOPACKAGE", "mypkg")
+		dir := t.TempDir()
+		input := filepath.Join(dir, "acl.txt")
+		output := filepath.Join(dir, "acl_gen.go")
+		if err := os.WriteFile(input, []byte(acl), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var stdout, stderr bytes.Buffer
+		if got, want := run([]string{"-i", input, "-o", output}, nil, &stdout, &stderr), 0; got != want {
+			t.Fatalf("exit code mismatch, got=%d, want=%d, stderr=%s", got, want, stderr.String())
+		}
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.SplitN(string(data), "\n", 2)[0], "// Code generated by ipaclgen from acl.txt. DO NOT EDIT."; got != want {
+			t.Errorf("header mismatch, got=%s, want=%s", got, want)
+		}
+		if !strings.Contains(string(data), "\npackage mypkg\n") {
+			t.Errorf("package name mismatch, got=%s", data)
+		}
+	})
+	t.Run("noPackage", func(t *testing.T) {
+		t.Setenv("GOPACKAGE", "")
+		var stdout, stderr bytes.Buffer
+		if got, want := run(nil, strings.NewReader(acl), &stdout, &stderr), 2; got != want {
+			t.Fatalf("exit code mismatch, got=%d, want=%d", got, want)
+		}
+	})
+	t.Run("invalidRule", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if got, want := run([]string{"-pkg", "acl"}, strings.NewReader("permit all\n"), &stdout, &stderr), 2; got != want {
+			t.Fatalf("exit code mismatch, got=%d, want=%d", got, want)
+		}
+		if stdout.Len() != 0 {
+			t.Errorf("want no output, got=%s", stdout.String())
+		}
+	})
+	t.Run("hostRule", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if got, want := run([]string{"-pkg", "acl"}, strings.NewReader("deny host:db.internal\nallow all\n"), &stdout, &stderr), 2; got != want {
+			t.Fatalf("exit code mismatch, got=%d, want=%d", got, want)
+		}
+		if got, want := stderr.String(), "ipaclgen: host rules are not supported: deny host:db.internal\n"; got != want {
+			t.Errorf("error mismatch, got=%q, want=%q", got, want)
+		}
+	})
+}
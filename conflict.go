@@ -0,0 +1,128 @@
+package ipacl
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Conflict is a pair of overlapping rules with different actions.
+type Conflict struct {
+	// Earlier is the index of the earlier rule.
+	Earlier int
+	// EarlierRule is the earlier rule.
+	EarlierRule Rule
+	// Later is the index of the later rule.
+	Later int
+	// LaterRule is the later rule.
+	LaterRule Rule
+	// Overlap is the overlapping range of the two rules, which is the more
+	// specific target of them.
+	Overlap netip.Prefix
+	// Deciding is the indexes of the rules which decide the addresses in
+	// Overlap under first-match semantics, in increasing order.
+	// It is not always Earlier, since the earlier rule may itself be
+	// shadowed by a preceding rule.
+	Deciding []int
+}
+
+// IntentionalOverlap is an overlap of two targets which is intentional and
+// must not be reported as a conflict.
+type IntentionalOverlap struct {
+	Specific netip.Prefix
+	General  netip.Prefix
+}
+
+// ConflictOptions is the options for FindConflicts.
+type ConflictOptions struct {
+	// IgnoreCarveOuts makes FindConflicts ignore every carve-out, that is
+	// a rule followed by a rule of a different action whose target strictly
+	// contains the target of the former, for example "deny 10.5.0.0/16"
+	// followed by "allow 10.0.0.0/8".
+	IgnoreCarveOuts bool
+
+	// Allowlist is the overlaps not to be reported. An overlap matches an
+	// element if the targets of the two rules are the Specific and General
+	// prefixes of the element, in either order.
+	Allowlist []IntentionalOverlap
+}
+
+// FindConflicts returns every pair of overlapping rules with different
+// actions, ordered by the later rule and then the earlier rule.
+// Unresolved host rules are ignored.
+func FindConflicts(rules []Rule, opts ConflictOptions) []Conflict {
+	var conflicts []Conflict
+	var attributedV4 []attributedRangeV4
+	var attributedV6 []attributedRangeV6
+	for j, later := range rules {
+		if later.host != "" {
+			continue
+		}
+		for i, earlier := range rules[:j] {
+			if earlier.host != "" || earlier.action == later.action || !earlier.target.Overlaps(later.target) {
+				continue
+			}
+			e, l := earlier.target.Masked(), later.target.Masked()
+			if opts.IgnoreCarveOuts && e.Bits() > l.Bits() {
+				continue
+			}
+			if opts.isAllowlisted(e, l) {
+				continue
+			}
+			overlap := e
+			if l.Bits() > e.Bits() {
+				overlap = l
+			}
+			var deciding []int
+			if overlap.Addr().Is4() {
+				if attributedV4 == nil {
+					attributedV4 = attributeRulesV4(rules)
+				}
+				deciding = rulesInAttributedRangesV4(attributedV4, v4RangeFromPrefix(overlap))
+			} else {
+				if attributedV6 == nil {
+					attributedV6 = attributeRulesV6(rules)
+				}
+				deciding = rulesInAttributedRangesV6(attributedV6, v6RangeFromPrefix(overlap))
+			}
+			conflicts = append(conflicts, Conflict{
+				Earlier:     i,
+				EarlierRule: earlier,
+				Later:       j,
+				LaterRule:   later,
+				Overlap:     overlap,
+				Deciding:    deciding,
+			})
+		}
+	}
+	return conflicts
+}
+
+func (o ConflictOptions) isAllowlisted(a, b netip.Prefix) bool {
+	for _, e := range o.Allowlist {
+		specific, general := e.Specific.Masked(), e.General.Masked()
+		if (a == specific && b == general) || (a == general && b == specific) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the string representation of the conflict.
+func (c Conflict) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "rule %d (%s) and rule %d (%s) overlap in %s, decided by rule",
+		c.Earlier, c.EarlierRule, c.Later, c.LaterRule, c.Overlap)
+	if len(c.Deciding) > 1 {
+		b.WriteByte('s')
+	}
+	for i, r := range c.Deciding {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte(' ')
+		b.WriteString(strconv.Itoa(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package ipacl
+
+import (
+	"net/netip"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func TestFindConflicts(t *testing.T) {
+	testCases := []struct {
+		rules string
+		opts  ConflictOptions
+		want  []string
+	}{
+		{
+			rules: "allow 10.0.0.0/8\ndeny 10.5.0.0/16\nallow 10.5.0.0/16\nallow all",
+			want: []string{
+				"rule 0 (allow 10.0.0.0/8) and rule 1 (deny 10.5.0.0/16) overlap in 10.5.0.0/16, decided by rule 0",
+				"rule 1 (deny 10.5.0.0/16) and rule 2 (allow 10.5.0.0/16) overlap in 10.5.0.0/16, decided by rule 0",
+				"rule 1 (deny 10.5.0.0/16) and rule 3 (allow 0.0.0.0/0) overlap in 10.5.0.0/16, decided by rule 0",
+			},
+		},
+		{
+			rules: "deny 10.5.0.0/16\nallow 10.0.0.0/8\ndeny all",
+			want: []string{
+				"rule 0 (deny 10.5.0.0/16) and rule 1 (allow 10.0.0.0/8) overlap in 10.5.0.0/16, decided by rule 0",
+				"rule 1 (allow 10.0.0.0/8) and rule 2 (deny 0.0.0.0/0) overlap in 10.0.0.0/8, decided by rules 0, 1",
+			},
+		},
+		{
+			rules: "deny 10.5.0.0/16\nallow 10.0.0.0/8\ndeny all",
+			opts:  ConflictOptions{IgnoreCarveOuts: true},
+			want:  nil,
+		},
+		{
+			rules: "allow 10.0.0.0/8\ndeny 10.5.0.0/16\ndeny 2001:db8::/32\nallow 2001:db8::/32\ndeny all",
+			opts:  ConflictOptions{IgnoreCarveOuts: true},
+			want: []string{
+				"rule 0 (allow 10.0.0.0/8) and rule 1 (deny 10.5.0.0/16) overlap in 10.5.0.0/16, decided by rule 0",
+				"rule 2 (deny 2001:db8::/32) and rule 3 (allow 2001:db8::/32) overlap in 2001:db8::/32, decided by rule 2",
+			},
+		},
+		{
+			rules: "allow 10.0.0.0/8\ndeny 10.5.0.0/16\ndeny 10.0.0.0/8",
+			opts: ConflictOptions{Allowlist: []IntentionalOverlap{
+				{Specific: netip.MustParsePrefix("10.5.0.0/16"), General: netip.MustParsePrefix("10.0.0.0/8")},
+			}},
+			want: []string{
+				"rule 0 (allow 10.0.0.0/8) and rule 2 (deny 10.0.0.0/8) overlap in 10.0.0.0/8, decided by rule 0",
+				"rule 1 (deny 10.5.0.0/16) and rule 3 (allow 0.0.0.0/0) overlap in 10.5.0.0/16, decided by rule 0",
+				"rule 2 (deny 10.0.0.0/8) and rule 3 (allow 0.0.0.0/0) overlap in 10.0.0.0/8, decided by rule 0",
+			},
+		},
+	}
+	for i, tc := range testCases {
+		rules, err := ParseRuleLines(tc.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, c := range FindConflicts(rules, tc.opts) {
+			got = append(got, c.String())
+		}
+		if diff := gocmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("result mismatch for test case %d, (-want +got):\n%s", i, diff)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package ipacl
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// RangeChange is a range of addresses whose action changed.
+type RangeChange struct {
+	// Range is the changed range.
+	Range Range
+	// OldAction is the action in the old rules.
+	OldAction Action
+	// NewAction is the action in the new rules.
+	NewAction Action
+	// OldRules is the indexes of the old rules which decide the addresses
+	// in the range. It is empty if no old rule matches them.
+	OldRules []int
+	// NewRules is the indexes of the new rules which decide the addresses
+	// in the range. It is empty if no new rule matches them.
+	NewRules []int
+}
+
+// DiffResult is the result of Diff.
+type DiffResult struct {
+	// Old is the old rules.
+	Old []Rule
+	// New is the new rules.
+	New []Rule
+	// Changes is the changed ranges, IPv4 ranges first, each family sorted
+	// in increasing order.
+	Changes []RangeChange
+	// V4Count is the number of IPv4 addresses whose action changed.
+	V4Count *big.Int
+	// V6Count is the number of IPv6 addresses whose action changed.
+	V6Count *big.Int
+}
+
+// Diff compares the compiled ranges of the old and new rules and returns
+// every range whose action changed.
+// It panics if old or new contain a host rule in the same way as
+// NewBinarySearch.
+func Diff(old, new []Rule) DiffResult {
+	oldSearch := NewBinarySearch(old)
+	newSearch := NewBinarySearch(new)
+	d := DiffResult{Old: old, New: new}
+	d.Changes, d.V4Count = appendRangeChangesV4(d.Changes, old, new,
+		oldSearch.v4RuleRanges(), newSearch.v4RuleRanges())
+	d.Changes, d.V6Count = appendRangeChangesV6(d.Changes, old, new,
+		oldSearch.v6RuleRanges(), newSearch.v6RuleRanges())
+	return d
+}
+
+// String returns the text representation of the result like a unified diff.
+// Each changed range is shown as a hunk with the old rules prefixed by "-"
+// and the new rules prefixed by "+".
+func (d DiffResult) String() string {
+	var b strings.Builder
+	b.WriteString("--- old\n+++ new\n")
+	for _, c := range d.Changes {
+		size := c.Range.Size()
+		unit := "addresses"
+		if size.IsInt64() && size.Int64() == 1 {
+			unit = "address"
+		}
+		fmt.Fprintf(&b, "@@ %s (%s %s) @@\n", c.Range, size, unit)
+		writeDiffRules(&b, '-', c.OldAction, d.Old, c.OldRules)
+		writeDiffRules(&b, '+', c.NewAction, d.New, c.NewRules)
+	}
+	fmt.Fprintf(&b, "%s IPv4 and %s IPv6 addresses changed\n", d.V4Count, d.V6Count)
+	return b.String()
+}
+
+func writeDiffRules(b *strings.Builder, sign byte, action Action, rules []Rule, indexes []int) {
+	if len(indexes) == 0 {
+		fmt.Fprintf(b, "%c%s (no matching rule)\n", sign, action)
+		return
+	}
+	for _, i := range indexes {
+		fmt.Fprintf(b, "%c%s (rule %d)\n", sign, rules[i], i)
+	}
+}
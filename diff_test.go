@@ -0,0 +1,88 @@
+package ipacl
+
+import (
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+	testCases := []struct {
+		old, new string
+		want     string
+	}{
+		{
+			old: "allow 10.0.0.0/8\ndeny all",
+			new: "allow 10.0.0.0/8\ndeny all",
+			want: "--- old\n+++ new\n" +
+				"0 IPv4 and 0 IPv6 addresses changed\n",
+		},
+		{
+			old: "allow 10.0.0.0/8\ndeny all",
+			new: "deny 10.1.0.0/16\nallow 10.0.0.0/8\ndeny all",
+			want: "--- old\n+++ new\n" +
+				"@@ 10.1.0.0-10.1.255.255 (65536 addresses) @@\n" +
+				"-allow 10.0.0.0/8 (rule 0)\n" +
+				"+deny 10.1.0.0/16 (rule 0)\n" +
+				"65536 IPv4 and 0 IPv6 addresses changed\n",
+		},
+		{
+			old: "allow 192.0.2.1\ndeny 192.0.2.0/24\nallow 2001:db8::/32\ndeny all",
+			new: "allow 192.0.2.0/25\ndeny 192.0.2.0/24\ndeny all",
+			want: "--- old\n+++ new\n" +
+				"@@ 192.0.2.0 (1 address) @@\n" +
+				"-deny 192.0.2.0/24 (rule 1)\n" +
+				"+allow 192.0.2.0/25 (rule 0)\n" +
+				"@@ 192.0.2.2-192.0.2.127 (126 addresses) @@\n" +
+				"-deny 192.0.2.0/24 (rule 1)\n" +
+				"+allow 192.0.2.0/25 (rule 0)\n" +
+				"@@ 2001:db8::-2001:db8:ffff:ffff:ffff:ffff:ffff:ffff (79228162514264337593543950336 addresses) @@\n" +
+				"-allow 2001:db8::/32 (rule 2)\n" +
+				"+deny ::/0 (rule 3)\n" +
+				"127 IPv4 and 79228162514264337593543950336 IPv6 addresses changed\n",
+		},
+		{
+			old: "deny 192.0.2.0/25\ndeny 192.0.2.128/25",
+			new: "",
+			want: "--- old\n+++ new\n" +
+				"@@ 192.0.2.0-192.0.2.255 (256 addresses) @@\n" +
+				"-deny 192.0.2.0/25 (rule 0)\n" +
+				"-deny 192.0.2.128/25 (rule 1)\n" +
+				"+allow 0.0.0.0/0 (rule 0)\n" +
+				"256 IPv4 and 0 IPv6 addresses changed\n",
+		},
+	}
+	for i, tc := range testCases {
+		oldRules, err := ParseRuleLines(tc.old)
+		if err != nil {
+			t.Fatal(err)
+		}
+		newRules, err := ParseRuleLines(tc.new)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := Diff(oldRules, newRules).String()
+		if diff := gocmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("result mismatch for test case %d, (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestDiff_noMatchingRule(t *testing.T) {
+	oldRules := []Rule{}
+	newRules, err := ParseRuleLines("deny 192.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := Diff(oldRules, newRules)
+	if got, want := len(d.Changes), 1; got != want {
+		t.Fatalf("change count mismatch, got=%d, want=%d", got, want)
+	}
+	c := d.Changes[0]
+	if len(c.OldRules) != 0 {
+		t.Errorf("old rules must be empty, got=%v", c.OldRules)
+	}
+	if got, want := c.OldAction, Allow; got != want {
+		t.Errorf("old action mismatch, got=%s, want=%s", got, want)
+	}
+}
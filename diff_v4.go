@@ -0,0 +1,68 @@
+package ipacl
+
+//go:generate sh -c "./gen_rule_range_v6_go.sh"
+
+import (
+	"math/big"
+)
+
+// appendRangeChangesV4 appends the changes between the ranges compiled from
+// oldRules and newRules to changes, and returns the changes and the number
+// of changed addresses.
+// oldRanges and newRanges must cover the whole address space and be sorted
+// in increasing order.
+func appendRangeChangesV4(changes []RangeChange, oldRules, newRules []Rule, oldRanges, newRanges []ruleRangeV4) ([]RangeChange, *big.Int) {
+	count := new(big.Int)
+	var oldAttributed, newAttributed []attributedRangeV4
+	var start v4Addr
+	i, j := 0, 0
+	for i < len(oldRanges) && j < len(newRanges) {
+		o, n := oldRanges[i], newRanges[j]
+		end := o.ipRange.end.Min(n.ipRange.end)
+		if o.action != n.action {
+			if oldAttributed == nil {
+				oldAttributed = attributeRulesV4(oldRules)
+				newAttributed = attributeRulesV4(newRules)
+			}
+			r := v4Range{start: start, end: end}
+			changes = append(changes, RangeChange{
+				Range:     r.NetIPRange(),
+				OldAction: o.action,
+				NewAction: n.action,
+				OldRules:  rulesInAttributedRangesV4(oldAttributed, r),
+				NewRules:  rulesInAttributedRangesV4(newAttributed, r),
+			})
+			count.Add(count, r.Size())
+		}
+		if o.ipRange.end.Compare(end) == 0 {
+			i++
+		}
+		if n.ipRange.end.Compare(end) == 0 {
+			j++
+		}
+		start = end.Next()
+	}
+	return changes, count
+}
+
+// firstDifferenceV4 returns the first address whose action differs between
+// a and b, and reports whether such an address exists.
+// a and b must cover the whole address space and be sorted in increasing order.
+func firstDifferenceV4(a, b []ruleRangeV4) (v4Addr, bool) {
+	var start v4Addr
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i].action != b[j].action {
+			return start, true
+		}
+		end := a[i].ipRange.end.Min(b[j].ipRange.end)
+		if a[i].ipRange.end.Compare(end) == 0 {
+			i++
+		}
+		if b[j].ipRange.end.Compare(end) == 0 {
+			j++
+		}
+		start = end.Next()
+	}
+	return start, false
+}
@@ -0,0 +1,29 @@
+package ipacl
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// Equivalent reports whether rules a and b give the same result for every
+// address with BinarySearch.Lookup. If they do not, it also returns an
+// address for which a and b give different results.
+// It panics if a or b contain a host rule in the same way as NewBinarySearch.
+func Equivalent(a, b []Rule) (equivalent bool, counterexample netip.Addr) {
+	sa := NewBinarySearch(a)
+	sb := NewBinarySearch(b)
+
+	if sa.v4EvenIndexIsDeny != sb.v4EvenIndexIsDeny || !slices.Equal(sa.v4EndAddrs, sb.v4EndAddrs) {
+		// The end addresses may still differ only in the implicit last range.
+		if addr, found := firstDifferenceV4(sa.v4RuleRanges(), sb.v4RuleRanges()); found {
+			return false, addr.NetIPAddr()
+		}
+	}
+	if sa.v6EvenIndexIsDeny != sb.v6EvenIndexIsDeny || !slices.Equal(sa.v6EndAddrs, sb.v6EndAddrs) ||
+		!slices.Equal(sa.v6EndHis, sb.v6EndHis) {
+		if addr, found := firstDifferenceV6(sa.v6RuleRanges(), sb.v6RuleRanges()); found {
+			return false, addr.NetIPAddr()
+		}
+	}
+	return true, netip.Addr{}
+}
@@ -0,0 +1,126 @@
+package ipacl
+
+import (
+	"math/rand"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestEquivalent(t *testing.T) {
+	testCases := []struct {
+		a, b               string
+		wantEquivalent     bool
+		wantCounterexample string
+	}{
+		{
+			a:              "deny 192.0.2.0/25\ndeny 192.0.2.128/25",
+			b:              "deny 192.0.2.0/24",
+			wantEquivalent: true,
+		},
+		{
+			a:              "allow 10.0.0.0/8\ndeny 10.1.0.0/16\ndeny all",
+			b:              "allow 10.0.0.0/8 # reformatted\n\ndeny  all",
+			wantEquivalent: true,
+		},
+		{
+			a:                  "allow 10.0.0.0/8\ndeny all",
+			b:                  "deny 10.1.0.0/16\nallow 10.0.0.0/8\ndeny all",
+			wantCounterexample: "10.1.0.0",
+		},
+		{
+			a:              "deny 2001:db8::1",
+			b:              "deny 2001:db8::1/128",
+			wantEquivalent: true,
+		},
+		{
+			a:                  "deny 2001:db8::1",
+			b:                  "deny 2001:db8::/32",
+			wantCounterexample: "2001:db8::",
+		},
+		{
+			a:                  "allow 192.0.2.0/24\ndeny all",
+			b:                  "allow 192.0.2.0/24\ndeny 0.0.0.0/0",
+			wantCounterexample: "::",
+		},
+	}
+	for i, tc := range testCases {
+		a, err := ParseRuleLines(tc.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseRuleLines(tc.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotEquivalent, gotCounterexample := Equivalent(a, b)
+		if gotEquivalent != tc.wantEquivalent {
+			t.Errorf("equivalent mismatch for test case %d, got=%v, want=%v", i, gotEquivalent, tc.wantEquivalent)
+		}
+		if tc.wantEquivalent {
+			if gotCounterexample.IsValid() {
+				t.Errorf("counterexample must be invalid for test case %d, got=%s", i, gotCounterexample)
+			}
+			continue
+		}
+		if got, want := gotCounterexample.String(), tc.wantCounterexample; got != want {
+			t.Errorf("counterexample mismatch for test case %d, got=%s, want=%s", i, got, want)
+		}
+		sa, sb := NewBinarySearch(a), NewBinarySearch(b)
+		if sa.Lookup(gotCounterexample) == sb.Lookup(gotCounterexample) {
+			t.Errorf("counterexample must give different results for test case %d, got=%s", i, gotCounterexample)
+		}
+	}
+}
+
+func TestEquivalent_implicitLastRange(t *testing.T) {
+	a := []Rule{NewRule(netip.MustParsePrefix("0.0.0.0/1"), Deny)}
+	b := []Rule{
+		NewRule(netip.MustParsePrefix("0.0.0.0/1"), Deny),
+		NewRule(netip.MustParsePrefix("128.0.0.0/1"), Allow),
+	}
+	if equivalent, counterexample := Equivalent(a, b); !equivalent {
+		t.Errorf("rules must be equivalent, counterexample=%s", counterexample)
+	}
+}
+
+func TestEquivalent_minimized(t *testing.T) {
+	base := netip.MustParsePrefix("192.0.2.0/27")
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		rules, err := ParseRuleLines(randomRuleLines(rnd, base, rnd.Intn(8)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if equivalent, counterexample := Equivalent(rules, MinimizeRules(rules)); !equivalent {
+			t.Errorf("minimized rules must be equivalent, rules=%s, counterexample=%s", Rules(rules), counterexample)
+		}
+	}
+}
+
+func FuzzEquivalent(f *testing.F) {
+	f.Add("allow 10.0.0.0/8\ndeny all", "deny 10.1.0.0/16\nallow 10.0.0.0/8\ndeny all", "10.1.0.0")
+	f.Fuzz(func(t *testing.T, sa, sb, input string) {
+		a, err := ParseRuleLines(sa)
+		if err != nil || hasHostRule(a) {
+			t.Skip()
+		}
+		b, err := ParseRuleLines(sb)
+		if err != nil || hasHostRule(b) {
+			t.Skip()
+		}
+		target, err := netip.ParseAddr(input)
+		if err != nil || strings.Contains(target.String(), "%") {
+			t.Skip()
+		}
+		ba, bb := NewBinarySearch(a), NewBinarySearch(b)
+		equivalent, counterexample := Equivalent(a, b)
+		if equivalent {
+			if ba.Lookup(target) != bb.Lookup(target) {
+				t.Errorf("rules must not be equivalent, a=%s, b=%s, ip=%s", Rules(a), Rules(b), target)
+			}
+		} else if ba.Lookup(counterexample) == bb.Lookup(counterexample) {
+			t.Errorf("invalid counterexample, a=%s, b=%s, counterexample=%s", Rules(a), Rules(b), counterexample)
+		}
+	})
+}
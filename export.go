@@ -0,0 +1,68 @@
+package ipacl
+
+import (
+	"iter"
+	"net/netip"
+)
+
+// EffectivePrefixes returns the minimal lists of non-overlapping IPv4 and
+// IPv6 prefixes whose addresses result in action with Lookup.
+// The prefixes are sorted in increasing order and can be used without
+// ordering, unlike rules.
+func (s *BinarySearch) EffectivePrefixes(action Action) (v4, v6 []netip.Prefix) {
+	for _, r := range s.v4RuleRanges() {
+		if r.action == action {
+			v4 = r.ipRange.AppendPrefixes(v4)
+		}
+	}
+	for _, r := range s.v6RuleRanges() {
+		if r.action == action {
+			v6 = r.ipRange.AppendPrefixes(v6)
+		}
+	}
+	return v4, v6
+}
+
+// Ranges returns an iterator over the effective ranges of both address
+// families with the action for each range. The IPv4 ranges are yielded
+// before the IPv6 ranges. See RangesV4 and RangesV6.
+func (s *BinarySearch) Ranges() iter.Seq2[Range, Action] {
+	return func(yield func(Range, Action) bool) {
+		for r, action := range s.RangesV4() {
+			if !yield(r, action) {
+				return
+			}
+		}
+		for r, action := range s.RangesV6() {
+			if !yield(r, action) {
+				return
+			}
+		}
+	}
+}
+
+// RangesV4 returns an iterator over the effective IPv4 ranges with the
+// action for each range. The ranges are yielded in increasing order, cover
+// the whole IPv4 address space and adjacent ranges have different actions.
+func (s *BinarySearch) RangesV4() iter.Seq2[Range, Action] {
+	return func(yield func(Range, Action) bool) {
+		for _, r := range s.v4RuleRanges() {
+			if !yield(r.ipRange.NetIPRange(), r.action) {
+				return
+			}
+		}
+	}
+}
+
+// RangesV6 returns an iterator over the effective IPv6 ranges with the
+// action for each range. The ranges are yielded in increasing order, cover
+// the whole IPv6 address space and adjacent ranges have different actions.
+func (s *BinarySearch) RangesV6() iter.Seq2[Range, Action] {
+	return func(yield func(Range, Action) bool) {
+		for _, r := range s.v6RuleRanges() {
+			if !yield(r.ipRange.NetIPRange(), r.action) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package ipacl
+
+import (
+	"fmt"
+	"iter"
+	"net/netip"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func TestBinarySearch_EffectivePrefixes(t *testing.T) {
+	testCases := []struct {
+		rules     string
+		wantAllow string
+		wantDeny  string
+	}{
+		{
+			rules:     "",
+			wantAllow: "[0.0.0.0/0] [::/0]",
+			wantDeny:  "[] []",
+		},
+		{
+			rules:     "deny 10.0.0.1\nallow 10.0.0.0/8\nallow 8000::/1\ndeny all",
+			wantAllow: "[10.0.0.0/32 10.0.0.2/31 10.0.0.4/30 10.0.0.8/29 10.0.0.16/28 10.0.0.32/27 10.0.0.64/26 10.0.0.128/25 10.0.1.0/24 10.0.2.0/23 10.0.4.0/22 10.0.8.0/21 10.0.16.0/20 10.0.32.0/19 10.0.64.0/18 10.0.128.0/17 10.1.0.0/16 10.2.0.0/15 10.4.0.0/14 10.8.0.0/13 10.16.0.0/12 10.32.0.0/11 10.64.0.0/10 10.128.0.0/9] [8000::/1]",
+			wantDeny:  "[0.0.0.0/5 8.0.0.0/7 10.0.0.1/32 11.0.0.0/8 12.0.0.0/6 16.0.0.0/4 32.0.0.0/3 64.0.0.0/2 128.0.0.0/1] [::/1]",
+		},
+	}
+	for i, tc := range testCases {
+		rules, err := ParseRuleLines(tc.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewBinarySearch(rules)
+		v4, v6 := s.EffectivePrefixes(Allow)
+		if diff := gocmp.Diff(tc.wantAllow, fmt.Sprint(v4, " ", v6)); diff != "" {
+			t.Errorf("allow prefixes mismatch for test case %d, (-want +got):\n%s", i, diff)
+		}
+		v4, v6 = s.EffectivePrefixes(Deny)
+		if diff := gocmp.Diff(tc.wantDeny, fmt.Sprint(v4, " ", v6)); diff != "" {
+			t.Errorf("deny prefixes mismatch for test case %d, (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestBinarySearch_EffectivePrefixes_lookup(t *testing.T) {
+	rules, err := ParseRuleLines(testRulesAndCasesData[len(testRulesAndCasesData)-1].rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewBinarySearch(rules)
+	for _, action := range []Action{Allow, Deny} {
+		v4, v6 := s.EffectivePrefixes(action)
+		for _, p := range append(v4, v6...) {
+			for _, ip := range []netip.Addr{p.Addr(), p.Masked().Addr()} {
+				if got := s.Lookup(ip); got != action {
+					t.Errorf("result mismatch, prefix=%s, ip=%s, got=%s, want=%s", p, ip, got, action)
+				}
+			}
+		}
+	}
+}
+
+func TestBinarySearch_Ranges(t *testing.T) {
+	testCases := []struct {
+		rules string
+		want  []string
+	}{
+		{
+			rules: "",
+			want: []string{
+				"0.0.0.0-255.255.255.255 allow",
+				"::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff allow",
+			},
+		},
+		{
+			rules: "deny 10.0.0.1\nallow 10.0.0.0/8\nallow 2001:db8::/32\ndeny all",
+			want: []string{
+				"0.0.0.0-9.255.255.255 deny",
+				"10.0.0.0 allow",
+				"10.0.0.1 deny",
+				"10.0.0.2-10.255.255.255 allow",
+				"11.0.0.0-255.255.255.255 deny",
+				"::-2001:db7:ffff:ffff:ffff:ffff:ffff:ffff deny",
+				"2001:db8::-2001:db8:ffff:ffff:ffff:ffff:ffff:ffff allow",
+				"2001:db9::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff deny",
+			},
+		},
+	}
+	for i, tc := range testCases {
+		rules, err := ParseRuleLines(tc.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewBinarySearch(rules)
+		var got []string
+		for r, action := range s.Ranges() {
+			got = append(got, fmt.Sprintf("%s %s", r, action))
+		}
+		if diff := gocmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("ranges mismatch for test case %d, (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestBinarySearch_Ranges_lookup(t *testing.T) {
+	rules, err := ParseRuleLines(testRulesAndCasesData[len(testRulesAndCasesData)-1].rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewBinarySearch(rules)
+	for name, ranges := range map[string]iter.Seq2[Range, Action]{"v4": s.RangesV4(), "v6": s.RangesV6()} {
+		var prev Range
+		for r, action := range ranges {
+			if prev.IsValid() && prev.End().Next() != r.Start() {
+				t.Errorf("ranges are not contiguous, family=%s, prev=%s, range=%s", name, prev, r)
+			}
+			for _, ip := range []netip.Addr{r.Start(), r.End()} {
+				if got := s.Lookup(ip); got != action {
+					t.Errorf("result mismatch, range=%s, ip=%s, got=%s, want=%s", r, ip, got, action)
+				}
+			}
+			prev = r
+		}
+		if prev.End().Next().IsValid() {
+			t.Errorf("ranges do not reach the last address, family=%s, last=%s", name, prev)
+		}
+	}
+}
+
+func TestBinarySearch_Ranges_break(t *testing.T) {
+	rules, err := ParseRuleLines("deny 10.0.0.0/8\ndeny 2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewBinarySearch(rules)
+	count := 0
+	for range s.Ranges() {
+		count++
+		if count == 2 {
+			break
+		}
+	}
+	if got, want := count, 2; got != want {
+		t.Errorf("count mismatch, got=%d, want=%d", got, want)
+	}
+}
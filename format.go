@@ -0,0 +1,96 @@
+package ipacl
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Format returns the canonical format of an access control list file.
+//
+// The grammar is same as ParseRuleLines, so src is rejected if and only if
+// ParseRuleLines rejects it. Rule order, comments and blank lines between
+// groups of lines are kept. Hostnames are lowercased, CIDRs are masked,
+// IPv6 addresses are compressed, targets are aligned and trailing comments
+// are aligned in each group of lines.
+//
+// Format is idempotent, so src is already formatted if the result equals to src.
+func Format(src []byte) ([]byte, error) {
+	type formatLine struct {
+		rule    string
+		comment string
+	}
+	var groups [][]formatLine
+	var group []formatLine
+	for i, text := range strings.Split(string(src), "\n") {
+		lineNo := i + 1
+		ruleText, comment, hasComment := strings.Cut(text, "#")
+		ruleText = strings.TrimSpace(ruleText)
+		if hasComment {
+			comment = "#" + strings.TrimRightFunc(comment, unicode.IsSpace)
+		}
+		if ruleText == "" && !hasComment {
+			if len(group) > 0 {
+				groups = append(groups, group)
+				group = nil
+			}
+			continue
+		}
+
+		var rule string
+		if ruleText != "" {
+			l, err := parseRuleLine(ruleText, lineNo)
+			if err != nil {
+				return nil, err
+			}
+			rule = l.String()
+		}
+		group = append(group, formatLine{rule: rule, comment: comment})
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+
+	var b strings.Builder
+	for i, group := range groups {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		commentColumn := 0
+		for _, l := range group {
+			if l.rule != "" && l.comment != "" {
+				commentColumn = max(commentColumn, len(l.rule)+1)
+			}
+		}
+		for _, l := range group {
+			switch {
+			case l.rule == "":
+				b.WriteString(l.comment)
+			case l.comment == "":
+				b.WriteString(l.rule)
+			default:
+				b.WriteString(l.rule)
+				b.WriteString(strings.Repeat(" ", commentColumn-len(l.rule)))
+				b.WriteString(l.comment)
+			}
+			b.WriteByte('\n')
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// String returns the canonical string representation of the rule line.
+func (l ruleLine) String() string {
+	var target string
+	switch {
+	case l.all:
+		target = "all"
+	case l.host != "":
+		target = hostTargetPrefix + l.host
+	case l.isAddr:
+		target = l.target.Addr().String()
+	default:
+		target = l.target.Masked().String()
+	}
+	return fmt.Sprintf("%-5s %s", l.action, target)
+}
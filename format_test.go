@@ -0,0 +1,110 @@
+package ipacl
+
+import (
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func TestFormat(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		testCases := []struct {
+			input string
+			want  string
+		}{
+			{input: "", want: ""},
+			{input: "\n\n", want: ""},
+			{
+				input: "  # header\n\n\n  allow   192.0.2.1/24 # docs\ndeny 2001:0DB8:0:0::1/32   #v6  \r\n\tdeny 198.51.100.7\n\n\nallow all\n",
+				want:  "# header\n\nallow 192.0.2.0/24  # docs\ndeny  2001:db8::/32 #v6\ndeny  198.51.100.7\n\nallow all\n",
+			},
+			{
+				input: "allow host:CI.Internal.Example # ci\ndeny all",
+				want:  "allow host:ci.internal.example # ci\ndeny  all\n",
+			},
+			{
+				input: "# only comments\n    # indented",
+				want:  "# only comments\n# indented\n",
+			},
+		}
+		for i, tc := range testCases {
+			got, err := Format([]byte(tc.input))
+			if err != nil {
+				t.Fatalf("want no error for test case %d, got: %s", i, err)
+			}
+			if diff := gocmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("result mismatch for test case %d, (-want +got):\n%s", i, diff)
+			}
+			again, err := Format(got)
+			if err != nil {
+				t.Fatalf("want no error for formatted test case %d, got: %s", i, err)
+			}
+			if diff := gocmp.Diff(string(got), string(again)); diff != "" {
+				t.Errorf("format is not idempotent for test case %d, (-first +second):\n%s", i, diff)
+			}
+			before, err := ParseRuleLines(tc.input)
+			if err != nil {
+				continue
+			}
+			after, err := ParseRuleLines(string(got))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameRules(before, after) {
+				t.Errorf("format changed rules for test case %d, before=%s, after=%s", i, Rules(before), Rules(after))
+			}
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		testCases := []struct {
+			input string
+			want  string
+		}{
+			{input: "# comment\nbad_field_count", want: "two fields must exist at line 2"},
+			{input: "permit 192.0.2.0/24", want: `invalid action "permit" at line 1, must be "allow" or "deny"`},
+			{input: "allow 192.0.2.256", want: `invalid target "192.0.2.256" at line 1, must be a valid a IPv4 CIDR, address or "all"`},
+			{input: "Allow 192.0.2.0/24", want: `invalid action "Allow" at line 1, must be "allow" or "deny"`},
+			{input: "deny ALL", want: `invalid target "ALL" at line 1, must be a valid a IPv4 CIDR, address or "all"`},
+			{input: "allow Host:ci.internal.example", want: `invalid target "Host:ci.internal.example" at line 1, must be a valid a IPv4 CIDR, address or "all"`},
+		}
+		for i, tc := range testCases {
+			_, err := Format([]byte(tc.input))
+			if err == nil {
+				t.Errorf("got no error for test case %d, want: %s", i, tc.want)
+			} else if got, want := err.Error(), tc.want; got != want {
+				t.Errorf("error message mismatch for test case %d, got: %s, want: %s", i, got, want)
+			}
+		}
+	})
+}
+
+// sameRules reports whether a and b are the same rules except that CIDRs
+// may not be masked.
+func sameRules(a, b []Rule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].action != b[i].action || a[i].host != b[i].host || a[i].target.Masked() != b[i].target.Masked() {
+			return false
+		}
+	}
+	return true
+}
+
+func FuzzFormat(f *testing.F) {
+	f.Add("# header\nallow 192.0.2.1/24 # docs\n\ndeny all\n")
+	f.Fuzz(func(t *testing.T, s string) {
+		formatted, err := Format([]byte(s))
+		if err != nil {
+			t.Skip()
+		}
+		again, err := Format(formatted)
+		if err != nil {
+			t.Fatalf("formatted text must be valid, formatted=%q, err=%s", formatted, err)
+		}
+		if string(again) != string(formatted) {
+			t.Errorf("format is not idempotent,\n first=%q\nsecond=%q", formatted, again)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package ipacl
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// GenerateOptions is the options for GenerateGo.
+type GenerateOptions struct {
+	// Package is the package name of the generated file.
+	Package string
+	// FuncName is the name of the generated lookup function.
+	FuncName string
+	// Source is the name of the source of the rules shown in the header
+	// comment. It may be empty.
+	Source string
+}
+
+// GenerateGo writes Go source code which contains the precompiled end
+// addresses of s and a lookup function of the name opts.FuncName with
+// the signature func(netip.Addr) ipacl.Action. The lookup function returns
+// the same results as s.Lookup.
+func GenerateGo(w io.Writer, s *BinarySearch, opts GenerateOptions) error {
+	if !token.IsIdentifier(opts.Package) {
+		return fmt.Errorf("invalid package name %q", opts.Package)
+	}
+	if !token.IsIdentifier(opts.FuncName) {
+		return fmt.Errorf("invalid function name %q", opts.FuncName)
+	}
+
+	// Names of variables are unexported even if the function is exported.
+	r, n := utf8.DecodeRuneInString(opts.FuncName)
+	prefix := string(unicode.ToLower(r)) + opts.FuncName[n:]
+
+	v4EndAddrs := make([]generatedEndAddr, len(s.v4EndAddrs))
+	for i, a := range s.v4EndAddrs {
+		v4EndAddrs[i] = generatedEndAddr{
+			Value:   fmt.Sprintf("%#08x", uint32(a)),
+			Comment: a.String(),
+		}
+	}
+	v6Ends := s.v6Ends()
+	v6EndAddrs := make([]generatedEndAddr, len(v6Ends))
+	for i, a := range v6Ends {
+		v6EndAddrs[i] = generatedEndAddr{
+			Value:   fmt.Sprintf("{%#016x, %#016x}", a.hi, a.lo),
+			Comment: a.String(),
+		}
+	}
+
+	var src bytes.Buffer
+	if err := generatedGoTemplate.Execute(&src, map[string]any{
+		"Package":           opts.Package,
+		"FuncName":          opts.FuncName,
+		"Source":            opts.Source,
+		"Prefix":            prefix,
+		"V4EndAddrs":        v4EndAddrs,
+		"V4EvenIndexIsDeny": s.v4EvenIndexIsDeny,
+		"V6EndAddrs":        v6EndAddrs,
+		"V6EvenIndexIsDeny": s.v6EvenIndexIsDeny,
+	}); err != nil {
+		return err
+	}
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatted)
+	return err
+}
+
+type generatedEndAddr struct {
+	Value   string
+	Comment string
+}
+
+var generatedGoTemplate = template.Must(template.New("").Parse(`// Code generated by ipaclgen{{if .Source}} from {{.Source}}{{end}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"cmp"
+	"net/netip"
+	"slices"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+var {{.Prefix}}V4EndAddrs = [...]uint32{
+{{- range .V4EndAddrs}}
+	{{.Value}}, // {{.Comment}}
+{{- end}}
+}
+
+const {{.Prefix}}V4EvenIndexIsDeny = {{.V4EvenIndexIsDeny}}
+
+var {{.Prefix}}V6EndAddrs = [...][2]uint64{
+{{- range .V6EndAddrs}}
+	{{.Value}}, // {{.Comment}}
+{{- end}}
+}
+
+const {{.Prefix}}V6EvenIndexIsDeny = {{.V6EvenIndexIsDeny}}
+
+// {{.FuncName}} lookups an IP address and returns the action defined in the access control list.
+func {{.FuncName}}(ip netip.Addr) ipacl.Action {
+	var i int
+	var evenIndexIsDeny bool
+	if ip.Is4() {
+		a := ip.As4()
+		target := uint32(a[0])<<24 | uint32(a[1])<<16 | uint32(a[2])<<8 | uint32(a[3])
+		i, _ = slices.BinarySearch({{.Prefix}}V4EndAddrs[:], target)
+		evenIndexIsDeny = {{.Prefix}}V4EvenIndexIsDeny
+	} else {
+		a := ip.As16()
+		var target [2]uint64
+		for j := 0; j < 16; j++ {
+			target[j/8] = target[j/8]<<8 | uint64(a[j])
+		}
+		i, _ = slices.BinarySearchFunc({{.Prefix}}V6EndAddrs[:], target, func(e, t [2]uint64) int {
+			if c := cmp.Compare(e[0], t[0]); c != 0 {
+				return c
+			}
+			return cmp.Compare(e[1], t[1])
+		})
+		evenIndexIsDeny = {{.Prefix}}V6EvenIndexIsDeny
+	}
+	if (i%2 == 0) == evenIndexIsDeny {
+		return ipacl.Deny
+	}
+	return ipacl.Allow
+}
+`))
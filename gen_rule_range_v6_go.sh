@@ -1,2 +1,5 @@
 #!/bin/sh
-sed 's/4/6/g' rule_range_v4.go | sed 's/go:generate.*/ This file is generated by `go generic`. DO NOT EDIT./' > rule_range_v6.go
+for v4 in rule_range_v4.go minimize_v4.go diff_v4.go stats_v4.go setops_v4.go layered_v4.go; do
+	v6=$(echo $v4 | sed 's/4/6/g')
+	sed 's/4/6/g' $v4 | sed 's/go:generate.*/ This file is generated by `go generic`. DO NOT EDIT./' > $v6
+done
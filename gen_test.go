@@ -0,0 +1,129 @@
+package ipacl
+
+import (
+	"bytes"
+	"math/rand"
+	"net/netip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerateGo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test which runs go command in short mode")
+	}
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	rules, err := ParseRuleLines(randomRuleLines(rnd, netip.MustParsePrefix("192.0.2.0/24"), 10) + "\n" +
+		randomRuleLines(rnd, netip.MustParsePrefix("2001:db8::/120"), 10) + "\n" +
+		"deny 198.51.100.0/24\nallow 2001:db8:1::/48\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewBinarySearch(rules)
+
+	var src bytes.Buffer
+	if err := GenerateGo(&src, &s, GenerateOptions{Package: "main", FuncName: "LookupACL", Source: "test.acl"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.SplitN(src.String(), "\n", 2)[0],
+		"// Code generated by ipaclgen from test.acl. DO NOT EDIT."; got != want {
+		t.Errorf("header mismatch, got=%s, want=%s", got, want)
+	}
+
+	var inputs []string
+	for i := 0; i < 256; i++ {
+		inputs = append(inputs, netip.AddrFrom4([4]byte{192, 0, 2, byte(i)}).String())
+	}
+	for i := 0; i < 256; i++ {
+		a := netip.MustParseAddr("2001:db8::").As16()
+		a[15] = byte(i)
+		inputs = append(inputs, netip.AddrFrom16(a).String())
+	}
+	inputs = append(inputs, "0.0.0.0", "198.51.100.1", "255.255.255.255",
+		"::", "2001:db8:1::1", "2001:db8:2::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+
+	// The generated code is built in a separate module which replaces this
+	// module with the source tree.
+	modDir, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	goMod := "module gentest\n\ngo 1.23\n\n" +
+		"require github.com/hnakamur/ipacl-go v0.0.0\n\n" +
+		"replace github.com/hnakamur/ipacl-go => " + strconv.Quote(modDir) + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	goSum, err := os.ReadFile("go.sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), goSum, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "acl.go"), src.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	const mainSrc = `package main
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+)
+
+func main() {
+	for _, arg := range os.Args[1:] {
+		fmt.Println(LookupACL(netip.MustParseAddr(arg)))
+	}
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(mainSrc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(goCmd, append([]string{"run", "."}, inputs...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=readonly", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run failed: %s\n%s", err, out)
+	}
+	results := strings.Fields(string(out))
+	if got, want := len(results), len(inputs); got != want {
+		t.Fatalf("result count mismatch, got=%d, want=%d", got, want)
+	}
+	for i, input := range inputs {
+		if got, want := results[i], s.Lookup(netip.MustParseAddr(input)).String(); got != want {
+			t.Errorf("result mismatch, input=%s, got=%s, want=%s", input, got, want)
+		}
+	}
+}
+
+func TestGenerateGo_error(t *testing.T) {
+	s := NewBinarySearch(nil)
+	testCases := []struct {
+		opts GenerateOptions
+		want string
+	}{
+		{opts: GenerateOptions{Package: "", FuncName: "Lookup"}, want: `invalid package name ""`},
+		{opts: GenerateOptions{Package: "acl", FuncName: "1lookup"}, want: `invalid function name "1lookup"`},
+	}
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		err := GenerateGo(&buf, &s, tc.opts)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("error mismatch, got=%v, want=%s", err, tc.want)
+		}
+	}
+}
@@ -1,5 +1,5 @@
 module github.com/hnakamur/ipacl-go
 
-go 1.21.2
+go 1.23
 
-require github.com/google/go-cmp v0.6.0 // indirect
+require github.com/google/go-cmp v0.6.0
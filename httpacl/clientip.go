@@ -0,0 +1,336 @@
+package httpacl
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+// ForwardingHeader is the type of the header which proxies use to pass
+// the client IP address.
+type ForwardingHeader int
+
+const (
+	// XForwardedFor is the X-Forwarded-For header, which has a
+	// comma-separated list of addresses appended by each proxy.
+	XForwardedFor ForwardingHeader = iota + 1
+	// Forwarded is the Forwarded header defined in RFC 7239.
+	Forwarded
+	// XRealIP is the X-Real-IP header, which has the single address
+	// set by the last proxy.
+	XRealIP
+)
+
+// String returns the header name.
+func (h ForwardingHeader) String() string {
+	switch h {
+	case XForwardedFor:
+		return "X-Forwarded-For"
+	case Forwarded:
+		return "Forwarded"
+	case XRealIP:
+		return "X-Real-IP"
+	default:
+		panic("invalid ForwardingHeader")
+	}
+}
+
+var (
+	// ErrMalformedHeader is the error returned when a forwarding header
+	// cannot be parsed.
+	ErrMalformedHeader = errors.New("httpacl: malformed forwarding header")
+	// ErrUnknownHop is the error returned when a hop which must be examined
+	// is "unknown" or an obfuscated identifier, so the client IP address
+	// cannot be determined.
+	ErrUnknownHop = errors.New("httpacl: unknown or obfuscated hop")
+)
+
+// TrustedProxyResolver resolves the client IP address of a request which
+// may have passed through trusted proxies.
+type TrustedProxyResolver struct {
+	trusted *ipacl.Layered
+	header  ForwardingHeader
+}
+
+// NewTrustedProxyResolver creates a TrustedProxyResolver. The addresses
+// allowed by trusted are trusted proxies, and header is the header which
+// the trusted proxies set.
+//
+// The addresses which no rule in trusted matches are not trusted, so trusted
+// should be parsed with ipacl.ParseLayer rather than ipacl.ParseRuleLines,
+// which appends the rules to allow all addresses.
+// It panics if trusted contains a host rule in the same way as
+// ipacl.NewBinarySearch.
+func NewTrustedProxyResolver(trusted []ipacl.Rule, header ForwardingHeader) *TrustedProxyResolver {
+	return &TrustedProxyResolver{
+		trusted: ipacl.NewLayered([]ipacl.Layer{{Rules: trusted}}, ipacl.Deny),
+		header:  header,
+	}
+}
+
+// ClientIP returns the client IP address of req. It can be used as
+// Options.ClientIP.
+//
+// If the peer in RemoteAddr is not a trusted proxy, the header is ignored
+// and the peer address is returned. Otherwise the hops in the header are
+// walked from the right, and the first hop which is not a trusted proxy is
+// returned. If all hops are trusted proxies, the leftmost hop is returned.
+//
+// Since only the hops appended by trusted proxies are examined, a client
+// cannot spoof its address by sending the header. An error is returned if
+// a hop to be examined is malformed, unknown or obfuscated.
+//
+// The X-Real-IP header is treated as a list of a single hop, so exactly one
+// trusted proxy, which is the first one the client connects to, must
+// overwrite the header and the others must pass it through.
+func (r *TrustedProxyResolver) ClientIP(req *http.Request) (netip.Addr, error) {
+	peer, err := RemoteAddrIP(req)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if r.trusted.Lookup(peer) != ipacl.Allow {
+		return peer, nil
+	}
+
+	switch r.header {
+	case XForwardedFor:
+		return r.walkHops(peer, splitXForwardedFor(req.Header.Values("X-Forwarded-For")))
+	case Forwarded:
+		hops, err := parseForwardedFor(req.Header.Values("Forwarded"))
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		return r.walkHops(peer, hops)
+	case XRealIP:
+		values := req.Header.Values("X-Real-IP")
+		switch len(values) {
+		case 0:
+			return peer, nil
+		case 1:
+			return r.walkHops(peer, []string{strings.TrimSpace(values[0])})
+		default:
+			return netip.Addr{}, fmt.Errorf("%w: multiple X-Real-IP headers", ErrMalformedHeader)
+		}
+	default:
+		panic("invalid ForwardingHeader")
+	}
+}
+
+func (r *TrustedProxyResolver) walkHops(peer netip.Addr, hops []string) (netip.Addr, error) {
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := parseNode(hops[i])
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		client = addr
+		if r.trusted.Lookup(addr) != ipacl.Allow {
+			break
+		}
+	}
+	return client, nil
+}
+
+// splitXForwardedFor returns the hops in the X-Forwarded-For header values
+// in order. The hops are not validated.
+func splitXForwardedFor(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// parseForwardedFor returns the values of the "for" parameters in the
+// Forwarded header values in order. An element without a "for" parameter
+// results in an empty hop, which is rejected by parseNode.
+func parseForwardedFor(values []string) ([]string, error) {
+	var hops []string
+	for _, v := range values {
+		p := forwardedParser{s: v}
+		for {
+			hop, err := p.element()
+			if err != nil {
+				return nil, err
+			}
+			hops = append(hops, hop)
+			if !p.consume(',') {
+				break
+			}
+		}
+		if p.skipSpaces(); p.i != len(p.s) {
+			return nil, fmt.Errorf("%w: unexpected character at %d in Forwarded header", ErrMalformedHeader, p.i)
+		}
+	}
+	return hops, nil
+}
+
+// forwardedParser is a parser of a Forwarded header value.
+//
+//	Forwarded   = 1#forwarded-element
+//	forwarded-element = [ forwarded-pair ] *( ";" [ forwarded-pair ] )
+//	forwarded-pair = token "=" value
+//	value       = token / quoted-string
+type forwardedParser struct {
+	s string
+	i int
+}
+
+func (p *forwardedParser) element() (string, error) {
+	var hop string
+	var hasFor bool
+	for {
+		p.skipSpaces()
+		if p.i < len(p.s) && p.s[p.i] != ';' && p.s[p.i] != ',' {
+			name := p.token()
+			if name == "" || !p.consume('=') {
+				return "", fmt.Errorf("%w: invalid pair at %d in Forwarded header", ErrMalformedHeader, p.i)
+			}
+			value, err := p.value()
+			if err != nil {
+				return "", err
+			}
+			if strings.EqualFold(name, "for") {
+				if hasFor {
+					return "", fmt.Errorf("%w: duplicated for parameter in Forwarded header", ErrMalformedHeader)
+				}
+				hop, hasFor = value, true
+			}
+		}
+		if !p.consume(';') {
+			return hop, nil
+		}
+	}
+}
+
+func (p *forwardedParser) value() (string, error) {
+	if p.i >= len(p.s) || p.s[p.i] != '"' {
+		if v := p.token(); v != "" {
+			return v, nil
+		}
+		return "", fmt.Errorf("%w: empty value at %d in Forwarded header", ErrMalformedHeader, p.i)
+	}
+
+	var b strings.Builder
+	for p.i++; p.i < len(p.s); p.i++ {
+		switch c := p.s[p.i]; c {
+		case '"':
+			p.i++
+			return b.String(), nil
+		case '\\':
+			p.i++
+			if p.i == len(p.s) {
+				break
+			}
+			b.WriteByte(p.s[p.i])
+		default:
+			if c < ' ' && c != '\t' || c == 0x7f {
+				return "", fmt.Errorf("%w: control character in Forwarded header", ErrMalformedHeader)
+			}
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("%w: unterminated quoted string in Forwarded header", ErrMalformedHeader)
+}
+
+func (p *forwardedParser) token() string {
+	start := p.i
+	for p.i < len(p.s) && isTokenChar(p.s[p.i]) {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+// consume skips spaces and c, and reports whether c is found.
+func (p *forwardedParser) consume(c byte) bool {
+	p.skipSpaces()
+	if p.i < len(p.s) && p.s[p.i] == c {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *forwardedParser) skipSpaces() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+func isTokenChar(c byte) bool {
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) != -1
+}
+
+// parseNode parses a hop in the form of an IP address optionally followed
+// by a port, where an IPv6 address must be enclosed in brackets if it has
+// a port. The port may be obfuscated as in the Forwarded header.
+// An IPv4-mapped IPv6 address is converted to an IPv4 address.
+func parseNode(s string) (netip.Addr, error) {
+	if s == "" {
+		return netip.Addr{}, fmt.Errorf("%w: empty hop", ErrMalformedHeader)
+	}
+	if strings.EqualFold(s, "unknown") || s[0] == '_' {
+		return netip.Addr{}, fmt.Errorf("%w: %q", ErrUnknownHop, s)
+	}
+
+	host, port := s, ""
+	if s[0] == '[' {
+		end := strings.IndexByte(s, ']')
+		if end == -1 {
+			return netip.Addr{}, fmt.Errorf("%w: invalid hop %q", ErrMalformedHeader, s)
+		}
+		host, port = s[1:end], s[end+1:]
+	} else if strings.Count(s, ":") == 1 {
+		i := strings.IndexByte(s, ':')
+		host, port = s[:i], s[i:]
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || addr.Zone() != "" || (port != "" && (port[0] != ':' || !isValidNodePort(port[1:]))) ||
+		(s[0] == '[' && !addr.Is6()) || (s[0] != '[' && port != "" && !addr.Is4()) {
+		return netip.Addr{}, fmt.Errorf("%w: invalid hop %q", ErrMalformedHeader, s)
+	}
+	return addr.Unmap(), nil
+}
+
+// isValidNodePort reports whether s is a port number or an obfuscated port.
+//
+//	node-port = port / obfport
+//	port      = 1*5DIGIT
+//	obfport   = "_" 1*(ALPHA / DIGIT / "." / "_" / "-")
+func isValidNodePort(s string) bool {
+	if s == "" {
+		return false
+	}
+	if s[0] == '_' {
+		if len(s) == 1 {
+			return false
+		}
+		for i := 1; i < len(s); i++ {
+			c := s[i]
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '_' || c == '-') {
+				return false
+			}
+		}
+		return true
+	}
+	if len(s) > 5 {
+		return false
+	}
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		n = n*10 + int(s[i]-'0')
+	}
+	return n <= 65535
+}
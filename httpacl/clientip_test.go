@@ -0,0 +1,147 @@
+package httpacl
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+func newTestTrustedProxies(t *testing.T) []ipacl.Rule {
+	t.Helper()
+	layer, err := ipacl.ParseLayer("trusted", "deny 10.0.0.5\nallow 10.0.0.0/8\nallow 2001:db8:ffff::/48")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return layer.Rules
+}
+
+func TestTrustedProxyResolver_ClientIP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		header     ForwardingHeader
+		remoteAddr string
+		values     []string
+		want       string
+		wantErr    error
+	}{
+		{name: "untrustedPeer", header: XForwardedFor, remoteAddr: "192.0.2.1:1234", values: []string{"198.51.100.1"}, want: "192.0.2.1"},
+		{name: "noHeader", header: XForwardedFor, remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "xffOneHop", header: XForwardedFor, remoteAddr: "10.0.0.1:1234", values: []string{"192.0.2.1"}, want: "192.0.2.1"},
+		{name: "xffSpoofed", header: XForwardedFor, remoteAddr: "10.0.0.1:1234", values: []string{"198.51.100.1, 192.0.2.1"}, want: "192.0.2.1"},
+		{name: "xffTrustedChain", header: XForwardedFor, remoteAddr: "10.0.0.1:1234", values: []string{"garbage, 192.0.2.1,10.0.0.2 , 10.0.0.3"}, want: "192.0.2.1"},
+		{name: "xffMultipleLines", header: XForwardedFor, remoteAddr: "10.0.0.1:1234", values: []string{"192.0.2.1", "10.0.0.2"}, want: "192.0.2.1"},
+		{name: "deniedPeer", header: XForwardedFor, remoteAddr: "10.0.0.5:1234", values: []string{"198.51.100.1"}, want: "10.0.0.5"},
+		{name: "xffDeniedHop", header: XForwardedFor, remoteAddr: "10.0.0.1:1234", values: []string{"192.0.2.1, 10.0.0.5"}, want: "10.0.0.5"},
+		{name: "xffAllTrusted", header: XForwardedFor, remoteAddr: "10.0.0.1:1234", values: []string{"10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+		{name: "xffIPv6", header: XForwardedFor, remoteAddr: "[2001:db8:ffff::1]:1234", values: []string{"2001:db8::1, 2001:db8:ffff::2"}, want: "2001:db8::1"},
+		{name: "xffWithPort", header: XForwardedFor, remoteAddr: "10.0.0.1:1234", values: []string{"192.0.2.1:5678"}, want: "192.0.2.1"},
+		{name: "xffMapped", header: XForwardedFor, remoteAddr: "10.0.0.1:1234", values: []string{"::ffff:10.0.0.2, ::ffff:192.0.2.1"}, want: "192.0.2.1"},
+		{name: "xffMalformed", header: XForwardedFor, remoteAddr: "10.0.0.1:1234", values: []string{"192.0.2.1, example.com"}, wantErr: ErrMalformedHeader},
+		{name: "xffEmptyHop", header: XForwardedFor, remoteAddr: "10.0.0.1:1234", values: []string{"192.0.2.1,,10.0.0.2"}, wantErr: ErrMalformedHeader},
+		{name: "xffZone", header: XForwardedFor, remoteAddr: "10.0.0.1:1234", values: []string{"fe80::1%eth0"}, wantErr: ErrMalformedHeader},
+		{name: "xffUnknown", header: XForwardedFor, remoteAddr: "10.0.0.1:1234", values: []string{"unknown"}, wantErr: ErrUnknownHop},
+		{name: "forwarded", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{"for=192.0.2.1;proto=https;by=10.0.0.1"}, want: "192.0.2.1"},
+		{name: "forwardedChain", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{`for=198.51.100.1, For="[2001:db8::1]:4711", for=10.0.0.2`}, want: "2001:db8::1"},
+		{name: "forwardedMultipleLines", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{"for=192.0.2.1", "for=10.0.0.2;host=example.com"}, want: "192.0.2.1"},
+		{name: "forwardedQuotedComma", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{`for=192.0.2.1;host="a,b", for=10.0.0.2`}, want: "192.0.2.1"},
+		{name: "forwardedObfuscatedPort", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{`for="192.0.2.1:_abc"`}, want: "192.0.2.1"},
+		{name: "forwardedIPv6WithoutBrackets", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{`for="2001:db8::1"`}, want: "2001:db8::1"},
+		{name: "forwardedObfuscated", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{"for=192.0.2.1, for=_hidden"}, wantErr: ErrUnknownHop},
+		{name: "forwardedUnknown", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{"for=unknown"}, wantErr: ErrUnknownHop},
+		{name: "forwardedObfuscatedLeftOfUntrusted", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{"for=_hidden, for=192.0.2.1"}, want: "192.0.2.1"},
+		{name: "forwardedNoFor", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{"proto=https"}, wantErr: ErrMalformedHeader},
+		{name: "forwardedDuplicatedFor", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{"for=192.0.2.1;for=10.0.0.2"}, wantErr: ErrMalformedHeader},
+		{name: "forwardedUnterminated", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{`for="192.0.2.1`}, wantErr: ErrMalformedHeader},
+		{name: "forwardedBadPair", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{"for 192.0.2.1"}, wantErr: ErrMalformedHeader},
+		{name: "forwardedBadBrackets", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{`for="[192.0.2.1]"`}, wantErr: ErrMalformedHeader},
+		{name: "forwardedBadPort", header: Forwarded, remoteAddr: "10.0.0.1:1234", values: []string{`for="[2001:db8::1]x80"`}, wantErr: ErrMalformedHeader},
+		{name: "xRealIP", header: XRealIP, remoteAddr: "10.0.0.1:1234", values: []string{" 192.0.2.1 "}, want: "192.0.2.1"},
+		{name: "xRealIPUntrustedPeer", header: XRealIP, remoteAddr: "192.0.2.1:1234", values: []string{"198.51.100.1"}, want: "192.0.2.1"},
+		{name: "xRealIPMultiple", header: XRealIP, remoteAddr: "10.0.0.1:1234", values: []string{"192.0.2.1", "198.51.100.1"}, wantErr: ErrMalformedHeader},
+		{name: "xRealIPTrusted", header: XRealIP, remoteAddr: "10.0.0.1:1234", values: []string{"10.0.0.2"}, want: "10.0.0.2"},
+		{name: "xRealIPMapped", header: XRealIP, remoteAddr: "10.0.0.1:1234", values: []string{"::ffff:192.0.2.1"}, want: "192.0.2.1"},
+		{name: "xRealIPMalformed", header: XRealIP, remoteAddr: "10.0.0.1:1234", values: []string{"192.0.2.1, 198.51.100.1"}, wantErr: ErrMalformedHeader},
+		{name: "invalidRemoteAddr", header: XForwardedFor, remoteAddr: "invalid", values: []string{"192.0.2.1"}, wantErr: ErrInvalidRemoteAddr},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewTrustedProxyResolver(newTestTrustedProxies(t), tc.header)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for _, v := range tc.values {
+				req.Header.Add(tc.header.String(), v)
+			}
+			got, err := r.ClientIP(req)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("error mismatch, got=%v, want=%v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want no error, got=%s", err)
+			}
+			if got.String() != tc.want {
+				t.Errorf("result mismatch, got=%s, want=%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrustedProxyResolver_noTrustedProxies(t *testing.T) {
+	r := NewTrustedProxyResolver(nil, XForwardedFor)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "192.0.2.1")
+	got, err := r.ClientIP(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "10.0.0.1"; got.String() != want {
+		t.Errorf("result mismatch, got=%s, want=%s", got, want)
+	}
+}
+
+func TestTrustedProxyResolver_middleware(t *testing.T) {
+	r := NewTrustedProxyResolver(newTestTrustedProxies(t), XForwardedFor)
+	h := Middleware(newTestMatcher(t), Options{ClientIP: r.ClientIP})(okHandler)
+	testCases := []struct {
+		remoteAddr string
+		xff        string
+		wantStatus int
+	}{
+		{remoteAddr: "10.0.0.1:1234", xff: "192.0.2.1", wantStatus: http.StatusOK},
+		{remoteAddr: "10.0.0.1:1234", xff: "192.0.2.1, 198.51.100.1", wantStatus: http.StatusForbidden},
+		{remoteAddr: "198.51.100.1:1234", xff: "192.0.2.1", wantStatus: http.StatusForbidden},
+		{remoteAddr: "10.0.0.1:1234", xff: "192.0.2.1, unknown", wantStatus: http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remoteAddr
+		req.Header.Set("X-Forwarded-For", tc.xff)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got, want := rec.Code, tc.wantStatus; got != want {
+			t.Errorf("status mismatch, remoteAddr=%s, xff=%s, got=%d, want=%d", tc.remoteAddr, tc.xff, got, want)
+		}
+	}
+}
+
+func FuzzParseForwardedFor(f *testing.F) {
+	f.Add(`for=192.0.2.1;proto=https, For="[2001:db8::1]:4711"`)
+	f.Add(`for="\"_x\"";by=unknown`)
+	f.Fuzz(func(t *testing.T, s string) {
+		hops, err := parseForwardedFor([]string{s})
+		if err != nil {
+			return
+		}
+		for _, hop := range hops {
+			if addr, err := parseNode(hop); err == nil && (!addr.IsValid() || addr.Zone() != "" || addr.Is4In6()) {
+				t.Errorf("invalid address, input=%q, hop=%q, addr=%s", s, hop, addr)
+			}
+		}
+	})
+}
@@ -0,0 +1,121 @@
+// Package httpacl provides a net/http middleware for access control by
+// a client's IP address with ipacl.
+package httpacl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+// Options is the options for Middleware.
+type Options struct {
+	// DenyStatus is the status code of the response for a denied request.
+	// If zero, http.StatusForbidden is used.
+	DenyStatus int
+
+	// DenyBody is the body of the response for a denied request.
+	// If empty, the status text followed by a newline is used.
+	DenyBody string
+
+	// DenyHandler serves denied requests if not nil. DenyStatus and
+	// DenyBody are ignored then. The decision can be obtained with
+	// DecisionFromContext in the handler.
+	DenyHandler http.Handler
+
+	// ClientIP extracts the client IP address from a request.
+	// If nil, RemoteAddrIP is used. If it returns an error,
+	// the request is denied.
+	ClientIP func(r *http.Request) (netip.Addr, error)
+}
+
+// Decision is the result of the access control for a request.
+type Decision struct {
+	// ClientIP is the client IP address. It is invalid if Err is not nil.
+	ClientIP netip.Addr
+
+	// Action is the action for the client IP address.
+	// It is always ipacl.Deny if Err is not nil.
+	Action ipacl.Action
+
+	// Err is the error in extracting the client IP address.
+	Err error
+}
+
+type decisionKey struct{}
+
+// DecisionFromContext returns the decision stored in ctx by Middleware.
+func DecisionFromContext(ctx context.Context) (Decision, bool) {
+	d, ok := ctx.Value(decisionKey{}).(Decision)
+	return d, ok
+}
+
+// Middleware returns a middleware which looks up the client IP address of
+// each request with m, and passes allowed requests to the next handler and
+// denied requests to the deny handler. The decision is stored in the request
+// context for both handlers.
+func Middleware(m ipacl.Matcher, opts Options) func(http.Handler) http.Handler {
+	clientIP := opts.ClientIP
+	if clientIP == nil {
+		clientIP = RemoteAddrIP
+	}
+	denyHandler := opts.DenyHandler
+	if denyHandler == nil {
+		denyHandler = newDenyHandler(opts.DenyStatus, opts.DenyBody)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var d Decision
+			d.ClientIP, d.Err = clientIP(r)
+			if d.Err != nil {
+				d.ClientIP = netip.Addr{}
+				d.Action = ipacl.Deny
+			} else {
+				d.Action = m.Lookup(d.ClientIP)
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), decisionKey{}, d))
+			if d.Action == ipacl.Allow {
+				next.ServeHTTP(w, r)
+			} else {
+				denyHandler.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+func newDenyHandler(status int, body string) http.Handler {
+	if status == 0 {
+		status = http.StatusForbidden
+	}
+	if body == "" {
+		body = http.StatusText(status) + "\n"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	})
+}
+
+// ErrInvalidRemoteAddr is the error returned by RemoteAddrIP when the
+// RemoteAddr of a request is not an IP address with or without a port.
+var ErrInvalidRemoteAddr = errors.New("httpacl: invalid RemoteAddr")
+
+// RemoteAddrIP returns the IP address in the RemoteAddr of r.
+// An IPv4-mapped IPv6 address is converted to an IPv4 address.
+func RemoteAddrIP(r *http.Request) (netip.Addr, error) {
+	if ap, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		return ap.Addr().Unmap(), nil
+	}
+	if ip, err := netip.ParseAddr(r.RemoteAddr); err == nil {
+		return ip.Unmap(), nil
+	}
+	return netip.Addr{}, fmt.Errorf("%w: %q", ErrInvalidRemoteAddr, r.RemoteAddr)
+}
@@ -0,0 +1,164 @@
+package httpacl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+func newTestMatcher(t *testing.T) ipacl.Matcher {
+	t.Helper()
+	rules, err := ipacl.ParseRuleLines(`
+		allow 192.0.2.0/24
+		allow 2001:db8::/32
+		deny  all
+		`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := ipacl.NewBinarySearch(rules)
+	return &s
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	d, ok := DecisionFromContext(r.Context())
+	if !ok {
+		http.Error(w, "no decision", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "ok %s", d.ClientIP)
+})
+
+func TestMiddleware(t *testing.T) {
+	h := Middleware(newTestMatcher(t), Options{})(okHandler)
+	testCases := []struct {
+		remoteAddr string
+		wantStatus int
+		wantBody   string
+	}{
+		{remoteAddr: "192.0.2.1:1234", wantStatus: http.StatusOK, wantBody: "ok 192.0.2.1"},
+		{remoteAddr: "198.51.100.1:1234", wantStatus: http.StatusForbidden, wantBody: "Forbidden\n"},
+		{remoteAddr: "[2001:db8::1]:1234", wantStatus: http.StatusOK, wantBody: "ok 2001:db8::1"},
+		{remoteAddr: "[2001:db9::1]:1234", wantStatus: http.StatusForbidden, wantBody: "Forbidden\n"},
+		{remoteAddr: "[::ffff:192.0.2.1]:1234", wantStatus: http.StatusOK, wantBody: "ok 192.0.2.1"},
+		{remoteAddr: "192.0.2.1", wantStatus: http.StatusOK, wantBody: "ok 192.0.2.1"},
+		{remoteAddr: "", wantStatus: http.StatusForbidden, wantBody: "Forbidden\n"},
+		{remoteAddr: "example.com:80", wantStatus: http.StatusForbidden, wantBody: "Forbidden\n"},
+		{remoteAddr: "192.0.2.1:port", wantStatus: http.StatusForbidden, wantBody: "Forbidden\n"},
+		{remoteAddr: "@", wantStatus: http.StatusForbidden, wantBody: "Forbidden\n"},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remoteAddr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got, want := rec.Code, tc.wantStatus; got != want {
+			t.Errorf("status mismatch, remoteAddr=%s, got=%d, want=%d", tc.remoteAddr, got, want)
+		}
+		if got, want := rec.Body.String(), tc.wantBody; got != want {
+			t.Errorf("body mismatch, remoteAddr=%s, got=%q, want=%q", tc.remoteAddr, got, want)
+		}
+	}
+}
+
+func TestMiddleware_denyOptions(t *testing.T) {
+	t.Run("statusAndBody", func(t *testing.T) {
+		h := Middleware(newTestMatcher(t), Options{
+			DenyStatus: http.StatusNotFound,
+			DenyBody:   "not here\n",
+		})(okHandler)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "198.51.100.1:1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got, want := rec.Code, http.StatusNotFound; got != want {
+			t.Errorf("status mismatch, got=%d, want=%d", got, want)
+		}
+		if got, want := rec.Body.String(), "not here\n"; got != want {
+			t.Errorf("body mismatch, got=%q, want=%q", got, want)
+		}
+	})
+	t.Run("handler", func(t *testing.T) {
+		var got Decision
+		h := Middleware(newTestMatcher(t), Options{
+			DenyStatus: http.StatusNotFound,
+			DenyHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = DecisionFromContext(r.Context())
+				w.WriteHeader(http.StatusTeapot)
+			}),
+		})(okHandler)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "198.51.100.1:1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got, want := rec.Code, http.StatusTeapot; got != want {
+			t.Errorf("status mismatch, got=%d, want=%d", got, want)
+		}
+		if got.ClientIP != netip.MustParseAddr("198.51.100.1") || got.Action != ipacl.Deny || got.Err != nil {
+			t.Errorf("decision mismatch, got=%+v", got)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "invalid"
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if got.ClientIP.IsValid() || got.Action != ipacl.Deny || !errors.Is(got.Err, ErrInvalidRemoteAddr) {
+			t.Errorf("decision mismatch, got=%+v", got)
+		}
+	})
+}
+
+func TestMiddleware_clientIP(t *testing.T) {
+	h := Middleware(newTestMatcher(t), Options{
+		ClientIP: func(r *http.Request) (netip.Addr, error) {
+			return netip.ParseAddr(r.Header.Get("X-Test-Client-IP"))
+		},
+	})(okHandler)
+	testCases := []struct {
+		clientIP   string
+		wantStatus int
+	}{
+		{clientIP: "192.0.2.1", wantStatus: http.StatusOK},
+		{clientIP: "198.51.100.1", wantStatus: http.StatusForbidden},
+		{clientIP: "", wantStatus: http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "198.51.100.1:1234"
+		req.Header.Set("X-Test-Client-IP", tc.clientIP)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got, want := rec.Code, tc.wantStatus; got != want {
+			t.Errorf("status mismatch, clientIP=%s, got=%d, want=%d", tc.clientIP, got, want)
+		}
+	}
+}
+
+func TestMiddleware_server(t *testing.T) {
+	rules, err := ipacl.ParseRuleLines("allow 127.0.0.0/8\ndeny all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := ipacl.NewBinarySearch(rules)
+	srv := httptest.NewServer(Middleware(&s, Options{})(okHandler))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "ok 127.0.0.1"; got != want {
+		t.Errorf("body mismatch, got=%q, want=%q", got, want)
+	}
+}
@@ -18,10 +18,29 @@ const (
 	Deny
 )
 
+// Matcher is the interface implemented by the compiled access control lists
+// such as BinarySearch, MappedBinarySearch, Layered and ResolvingACL.
+type Matcher interface {
+	// Lookup lookups an IP address and returns the action defined
+	// in the access control list.
+	Lookup(ip netip.Addr) Action
+}
+
+var (
+	_ Matcher = (*BinarySearch)(nil)
+	_ Matcher = (*MappedBinarySearch)(nil)
+	_ Matcher = (*Layered)(nil)
+	_ Matcher = (*ResolvingACL)(nil)
+)
+
 // Rule is a unit of rule to allow or deny IP addresses in the target CIDR.
 type Rule struct {
 	target netip.Prefix
 	action Action
+
+	// host is the target hostname of a rule created with NewHostRule.
+	// It is empty for rules with a CIDR target.
+	host string
 }
 
 // NewRule creates a rule with a CIDR and an action.
@@ -29,8 +48,36 @@ func NewRule(target netip.Prefix, action Action) Rule {
 	return Rule{target: target, action: action}
 }
 
+// NewHostRule creates a rule with a hostname and an action.
+// The hostname is lowercased since hostnames are case-insensitive.
+// A host rule must be resolved with ResolveRules or NewResolvingACL
+// before compiled.
+func NewHostRule(host string, action Action) Rule {
+	return Rule{host: strings.ToLower(host), action: action}
+}
+
+// Target returns the target CIDR of the rule.
+// It returns an invalid prefix for a host rule.
+func (r Rule) Target() netip.Prefix {
+	return r.target
+}
+
+// Host returns the target hostname of the rule.
+// It returns an empty string for a rule with a CIDR target.
+func (r Rule) Host() string {
+	return r.host
+}
+
+// Action returns the action of the rule.
+func (r Rule) Action() Action {
+	return r.action
+}
+
 // String returns the string representation of the rule.
 func (r Rule) String() string {
+	if r.host != "" {
+		return fmt.Sprintf("%s %s%s", r.action, hostTargetPrefix, r.host)
+	}
 	return fmt.Sprintf("%s %s", r.action, r.target)
 }
 
@@ -86,11 +133,40 @@ func (a Action) String() string {
 var allIPv4CIDR = netip.PrefixFrom(netip.AddrFrom4([4]byte{}), 0)
 var allIPv6CIDR = netip.PrefixFrom(netip.AddrFrom16([16]byte{}), 0)
 
+const hostTargetPrefix = "host:"
+
 // ParseRuleLines parses rules in multiple lines.
+// The rules to allow all IPv4 and IPv6 addresses are appended unless
+// the rules for the whole address space of each family exist.
 func ParseRuleLines(s string) (rules []Rule, err error) {
+	rules, err = parseRuleLines(s)
+	if err != nil {
+		return nil, err
+	}
+
 	seenV4DefaultAction := false
 	seenV6DefaultAction := false
+	for _, rule := range rules {
+		if rule.host == "" && rule.target.Bits() == 0 {
+			if rule.target.Addr().Is4() {
+				seenV4DefaultAction = true
+			} else {
+				seenV6DefaultAction = true
+			}
+		}
+	}
+	if !seenV4DefaultAction {
+		rules = append(rules, NewRule(allIPv4CIDR, Allow))
+	}
+	if !seenV6DefaultAction {
+		rules = append(rules, NewRule(allIPv6CIDR, Allow))
+	}
+	return rules, nil
+}
 
+// parseRuleLines parses rules in multiple lines without appending
+// the default rules.
+func parseRuleLines(s string) (rules []Rule, err error) {
 	lines := strings.Split(s, "\n")
 	for i, line := range lines {
 		lineNo := i + 1
@@ -99,50 +175,70 @@ func ParseRuleLines(s string) (rules []Rule, err error) {
 		if len(line) == 0 {
 			continue
 		}
-		fields := strings.Fields(line)
-		if len(fields) != 2 {
-			return nil, fmt.Errorf("two fields must exist at line %d", lineNo)
-		}
-		action, err := ParseAction(fields[0])
+		l, err := parseRuleLine(line, lineNo)
 		if err != nil {
-			return nil, fmt.Errorf(`invalid action %q at line %d, must be "allow" or "deny"`, fields[0], lineNo)
+			return nil, err
 		}
 
-		var target netip.Prefix
-		if fields[1] == "all" {
-			rules = append(rules, NewRule(allIPv4CIDR, action))
-			seenV4DefaultAction = true
-			rules = append(rules, NewRule(allIPv6CIDR, action))
-			seenV6DefaultAction = true
-		} else {
-			target, err = netip.ParsePrefix(fields[1])
-			if err != nil {
-				ip, err := netip.ParseAddr(fields[1])
-				if err != nil {
-					return nil, fmt.Errorf(`invalid target %q at line %d, must be a valid a IPv4 CIDR, address or "all"`, fields[1], lineNo)
-				} else if strings.Contains(target.String(), "%") {
-					return nil, fmt.Errorf(`invalid target %q at line %d, must not contain "%%"`, fields[1], lineNo)
-				}
-				target = netip.PrefixFrom(ip, 32)
-			} else if strings.Contains(target.String(), "%") {
-				return nil, fmt.Errorf(`invalid target %q at line %d, must not contain "%%"`, fields[1], lineNo)
-			}
-			rules = append(rules, NewRule(target, action))
-			if target.Bits() == 0 {
-				if target.Addr().Is4() {
-					seenV4DefaultAction = true
-				} else {
-					seenV6DefaultAction = true
-				}
-			}
+		switch {
+		case l.all:
+			rules = append(rules, NewRule(allIPv4CIDR, l.action), NewRule(allIPv6CIDR, l.action))
+		case l.host != "":
+			rules = append(rules, NewHostRule(l.host, l.action))
+		default:
+			rules = append(rules, NewRule(l.target, l.action))
 		}
 	}
+	return rules, nil
+}
 
-	if !seenV4DefaultAction {
-		rules = append(rules, NewRule(allIPv4CIDR, Allow))
+// ruleLine is a parsed rule line.
+type ruleLine struct {
+	action Action
+
+	// all is true if the target is "all".
+	all bool
+	// host is the hostname if the target is a hostname.
+	host string
+	// target is the target if the target is a CIDR or an address.
+	target netip.Prefix
+	// isAddr is true if the target is an address without a prefix length.
+	isAddr bool
+}
+
+// parseRuleLine parses a rule line whose comment and surrounding spaces
+// are already removed.
+func parseRuleLine(line string, lineNo int) (ruleLine, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return ruleLine{}, fmt.Errorf("two fields must exist at line %d", lineNo)
 	}
-	if !seenV6DefaultAction {
-		rules = append(rules, NewRule(allIPv6CIDR, Allow))
+	action, err := ParseAction(fields[0])
+	if err != nil {
+		return ruleLine{}, fmt.Errorf(`invalid action %q at line %d, must be "allow" or "deny"`, fields[0], lineNo)
 	}
-	return rules, nil
+
+	if fields[1] == "all" {
+		return ruleLine{action: action, all: true}, nil
+	}
+	if host, ok := strings.CutPrefix(fields[1], hostTargetPrefix); ok {
+		if host == "" {
+			return ruleLine{}, fmt.Errorf(`empty hostname at line %d`, lineNo)
+		}
+		return ruleLine{action: action, host: strings.ToLower(host)}, nil
+	}
+
+	target, err := netip.ParsePrefix(fields[1])
+	if err != nil {
+		ip, err := netip.ParseAddr(fields[1])
+		if err != nil {
+			return ruleLine{}, fmt.Errorf(`invalid target %q at line %d, must be a valid a IPv4 CIDR, address or "all"`, fields[1], lineNo)
+		} else if ip.Zone() != "" {
+			return ruleLine{}, fmt.Errorf(`invalid target %q at line %d, must not contain "%%"`, fields[1], lineNo)
+		}
+		return ruleLine{action: action, target: netip.PrefixFrom(ip, ip.BitLen()), isAddr: true}, nil
+	} else if strings.Contains(target.String(), "%") {
+		return ruleLine{}, fmt.Errorf(`invalid target %q at line %d, must not contain "%%"`, fields[1], lineNo)
+	}
+	return ruleLine{action: action, target: target}, nil
 }
@@ -20,6 +20,9 @@ func TestParseRuleLines(t *testing.T) {
 			{input: "# comment\nallow 192.0.2.0/24 # comment\ndeny 198.51.100.0/24\nallow 203.0.113.0/0\n", want: "allow 192.0.2.0/24, deny 198.51.100.0/24, allow 203.0.113.0/0, allow ::/0"},
 			{input: "# comment\nallow 192.0.2.0/24 # comment\ndeny 198.51.100.0/24\ndeny 203.0.113.0/0\n", want: "allow 192.0.2.0/24, deny 198.51.100.0/24, deny 203.0.113.0/0, allow ::/0"},
 			{input: "# empty\n", want: "allow 0.0.0.0/0, allow ::/0"},
+			{input: "deny 2001:db8::1\n", want: "deny 2001:db8::1/128, allow 0.0.0.0/0, allow ::/0"},
+			{input: "allow host:ci.internal.example\ndeny all\n", want: "allow host:ci.internal.example, deny 0.0.0.0/0, deny ::/0"},
+			{input: "allow host:CI.Internal.Example\ndeny all\n", want: "allow host:ci.internal.example, deny 0.0.0.0/0, deny ::/0"},
 			{input: `deny 192.168.255.250/32
 			deny 192.168.255.248/32
 			deny 192.168.255.246/32
@@ -139,6 +142,7 @@ func TestParseRuleLines(t *testing.T) {
 			{input: "bad_field_count", want: "two fields must exist at line 1"},
 			{input: "bad_action 192.0.2.0/24", want: `invalid action "bad_action" at line 1, must be "allow" or "deny"`},
 			{input: "allow 192.0.2.256", want: `invalid target "192.0.2.256" at line 1, must be a valid a IPv4 CIDR, address or "all"`},
+			{input: "allow host:", want: `empty hostname at line 1`},
 		}
 		for i, tc := range testCases {
 			_, err := ParseRuleLines(tc.input)
@@ -150,3 +154,14 @@ func TestParseRuleLines(t *testing.T) {
 		}
 	})
 }
+
+// hasHostRule reports whether rules contain a host rule, which cannot be
+// compiled without resolution.
+func hasHostRule(rules []Rule) bool {
+	for _, rule := range rules {
+		if rule.host != "" {
+			return true
+		}
+	}
+	return false
+}
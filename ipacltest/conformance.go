@@ -0,0 +1,71 @@
+package ipacltest
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+// RunConformance tests that the matchers created by newMatcher give the same
+// results as LinearMatcher for fixed and random rules. newMatcher is called
+// with the subtest which uses the matcher. All rule sets cover
+// all addresses with the rules for 0.0.0.0/0 and ::/0, except the empty
+// rule set.
+func RunConformance(t *testing.T, newMatcher func(t *testing.T, rules []ipacl.Rule) ipacl.Matcher) {
+	t.Helper()
+	t.Run("empty", func(t *testing.T) {
+		checkConformance(t, nil, EdgeAddrs, newMatcher)
+	})
+	t.Run("fixed", func(t *testing.T) {
+		for _, s := range conformanceRuleLines {
+			rules, err := ipacl.ParseRuleLines(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			addrs := append(BoundaryAddrs(rules), EdgeAddrs...)
+			checkConformance(t, rules, addrs, newMatcher)
+		}
+	})
+	t.Run("random", func(t *testing.T) {
+		rnd := rand.New(rand.NewSource(1))
+		n := 500
+		if testing.Short() {
+			n = 50
+		}
+		for i := 0; i < n; i++ {
+			rules := RandomRules(rnd, rnd.Intn(20))
+			addrs := append(BoundaryAddrs(rules), EdgeAddrs...)
+			addrs = append(addrs, RandomAddrs(rnd, rules, 50)...)
+			checkConformance(t, rules, addrs, newMatcher)
+		}
+	})
+}
+
+var conformanceRuleLines = []string{
+	"",
+	"deny all",
+	"deny 0.0.0.0/0",
+	"deny ::/0",
+	"deny 0.0.0.0/32\ndeny 255.255.255.255/32",
+	"deny ::/128\ndeny ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128",
+	"allow 192.0.2.1/32\ndeny 192.0.2.0/24\nallow 192.0.0.0/16\ndeny all",
+	"deny 192.0.2.0/24\nallow 192.0.2.1/32",
+	"allow 2001:db8::1/128\ndeny 2001:db8::/32\nallow 2001::/16\ndeny all",
+	"deny ::ffff:0:0/96\nallow 0.0.0.0/0\ndeny all",
+	"deny 0.0.0.0/1\nallow 128.0.0.0/1\ndeny ::/1\nallow 8000::/1",
+	"allow 0:0:0:1::/64\ndeny ::/63\ndeny all",
+}
+
+func checkConformance(t *testing.T, rules []ipacl.Rule, addrs []netip.Addr, newMatcher func(t *testing.T, rules []ipacl.Rule) ipacl.Matcher) {
+	t.Helper()
+	m := newMatcher(t, rules)
+	ref := NewLinearMatcher(rules)
+	for _, addr := range addrs {
+		if got, want := m.Lookup(addr), ref.Lookup(addr); got != want {
+			t.Errorf("result mismatch, rules=%s, ip=%s, got=%s, want=%s", ipacl.Rules(rules), addr, got, want)
+			return
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package ipacltest provides utilities for testing implementations of
+// ipacl.Matcher: random rule and address generators, a reference matcher
+// and a conformance suite.
+package ipacltest
+
+import (
+	"math/rand"
+	"net/netip"
+	"slices"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+// EdgeAddrs is the list of addresses which are likely to reveal bugs,
+// such as the first and last addresses of each address family.
+var EdgeAddrs = []netip.Addr{
+	netip.MustParseAddr("0.0.0.0"),
+	netip.MustParseAddr("0.0.0.1"),
+	netip.MustParseAddr("127.255.255.255"),
+	netip.MustParseAddr("128.0.0.0"),
+	netip.MustParseAddr("255.255.255.254"),
+	netip.MustParseAddr("255.255.255.255"),
+	netip.MustParseAddr("::"),
+	netip.MustParseAddr("::1"),
+	netip.MustParseAddr("::ffff:0.0.0.0"),
+	netip.MustParseAddr("::ffff:255.255.255.255"),
+	netip.MustParseAddr("0:0:0:0:ffff:ffff:ffff:ffff"),
+	netip.MustParseAddr("0:0:0:1::"),
+	netip.MustParseAddr("7fff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"),
+	netip.MustParseAddr("8000::"),
+	netip.MustParseAddr("fe80::1%eth0"),
+	netip.MustParseAddr("ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe"),
+	netip.MustParseAddr("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"),
+}
+
+// RandomRules returns n random rules followed by the rules for 0.0.0.0/0
+// and ::/0 with random actions, so that the rules cover all addresses
+// as ipacl.ParseRuleLines ensures.
+//
+// The rules are biased toward the edge addresses and toward overlapping
+// with the preceding rules.
+func RandomRules(rnd *rand.Rand, n int) []ipacl.Rule {
+	rules := make([]ipacl.Rule, 0, n+2)
+	for i := 0; i < n; i++ {
+		var addr netip.Addr
+		if i > 0 && rnd.Intn(2) == 0 {
+			// Overlap with a preceding rule.
+			addr = randomAddrInPrefix(rnd, rules[rnd.Intn(i)].Target())
+		} else {
+			addr = randomAddr(rnd)
+		}
+		bits := randomBits(rnd, addr.BitLen())
+		rules = append(rules, ipacl.NewRule(netip.PrefixFrom(addr, bits).Masked(), randomAction(rnd)))
+	}
+	rules = append(rules,
+		ipacl.NewRule(netip.MustParsePrefix("0.0.0.0/0"), randomAction(rnd)),
+		ipacl.NewRule(netip.MustParsePrefix("::/0"), randomAction(rnd)))
+	return rules
+}
+
+// RandomAddrs returns n random addresses which are biased toward the
+// boundaries of the targets of rules and EdgeAddrs.
+func RandomAddrs(rnd *rand.Rand, rules []ipacl.Rule, n int) []netip.Addr {
+	boundaries := BoundaryAddrs(rules)
+	addrs := make([]netip.Addr, n)
+	for i := range addrs {
+		switch {
+		case len(boundaries) > 0 && rnd.Intn(2) == 0:
+			addrs[i] = boundaries[rnd.Intn(len(boundaries))]
+		case len(rules) > 0 && rnd.Intn(2) == 0:
+			addrs[i] = randomAddrInPrefix(rnd, rules[rnd.Intn(len(rules))].Target())
+		default:
+			addrs[i] = randomAddr(rnd)
+		}
+	}
+	return addrs
+}
+
+// BoundaryAddrs returns the first and last addresses of the targets of rules
+// and their neighbors, sorted without duplicates.
+func BoundaryAddrs(rules []ipacl.Rule) []netip.Addr {
+	var addrs []netip.Addr
+	for _, rule := range rules {
+		p := rule.Target()
+		if !p.IsValid() {
+			continue
+		}
+		first := p.Masked().Addr()
+		last := lastAddr(p)
+		for _, addr := range []netip.Addr{first.Prev(), first, last, last.Next()} {
+			if addr.IsValid() {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	slices.SortFunc(addrs, netip.Addr.Compare)
+	return slices.Compact(addrs)
+}
+
+func randomAction(rnd *rand.Rand) ipacl.Action {
+	if rnd.Intn(2) == 0 {
+		return ipacl.Allow
+	}
+	return ipacl.Deny
+}
+
+func randomAddr(rnd *rand.Rand) netip.Addr {
+	switch rnd.Intn(4) {
+	case 0:
+		return EdgeAddrs[rnd.Intn(len(EdgeAddrs))].WithZone("")
+	case 1:
+		var a [4]byte
+		rnd.Read(a[:])
+		return netip.AddrFrom4(a)
+	default:
+		var a [16]byte
+		rnd.Read(a[:])
+		// Keep some high bits zero so that addresses are close to
+		// each other more often than with uniform random addresses.
+		clear(a[:rnd.Intn(len(a))])
+		return netip.AddrFrom16(a)
+	}
+}
+
+func randomAddrInPrefix(rnd *rand.Rand, p netip.Prefix) netip.Addr {
+	if !p.IsValid() {
+		return randomAddr(rnd)
+	}
+	switch rnd.Intn(3) {
+	case 0:
+		return p.Masked().Addr()
+	case 1:
+		return lastAddr(p)
+	default:
+		a := p.Addr().AsSlice()
+		for i := p.Bits(); i < len(a)*8; i++ {
+			if rnd.Intn(2) == 0 {
+				a[i/8] ^= 0x80 >> (i % 8)
+			}
+		}
+		addr, _ := netip.AddrFromSlice(a)
+		return addr
+	}
+}
+
+// randomBits returns a random prefix length which is biased toward
+// the shortest, the longest and the byte boundaries.
+func randomBits(rnd *rand.Rand, bitLen int) int {
+	switch rnd.Intn(4) {
+	case 0:
+		return []int{0, 1, bitLen - 1, bitLen}[rnd.Intn(4)]
+	case 1:
+		return 8 * rnd.Intn(bitLen/8+1)
+	default:
+		return rnd.Intn(bitLen + 1)
+	}
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	a := p.Masked().Addr().AsSlice()
+	for i := p.Bits(); i < len(a)*8; i++ {
+		a[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(a)
+	return addr
+}
@@ -0,0 +1,102 @@
+package ipacltest
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+func TestRunConformance(t *testing.T) {
+	t.Run("BinarySearch", func(t *testing.T) {
+		RunConformance(t, func(t *testing.T, rules []ipacl.Rule) ipacl.Matcher {
+			s := ipacl.NewBinarySearch(rules)
+			return &s
+		})
+	})
+	t.Run("MappedBinarySearch", func(t *testing.T) {
+		RunConformance(t, func(t *testing.T, rules []ipacl.Rule) ipacl.Matcher {
+			s := ipacl.NewBinarySearch(rules)
+			m, err := ipacl.NewMappedBinarySearch(s.AppendMapped(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return m
+		})
+	})
+	t.Run("Layered", func(t *testing.T) {
+		RunConformance(t, func(t *testing.T, rules []ipacl.Rule) ipacl.Matcher {
+			return ipacl.NewLayered([]ipacl.Layer{{Name: "main", Rules: rules}}, ipacl.Allow)
+		})
+	})
+	t.Run("Minimized", func(t *testing.T) {
+		RunConformance(t, func(t *testing.T, rules []ipacl.Rule) ipacl.Matcher {
+			s := ipacl.NewBinarySearch(ipacl.MinimizeRules(rules))
+			return &s
+		})
+	})
+}
+
+func TestRunConformance_detectsBug(t *testing.T) {
+	// RunConformance cannot be run with a buggy matcher without failing
+	// this test, so check that the generated inputs tell the buggy matcher
+	// from the reference instead.
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		rules := RandomRules(rnd, 10)
+		ref := NewLinearMatcher(rules)
+		buggy := NewLinearMatcher(rules[1:])
+		for _, addr := range append(BoundaryAddrs(rules), RandomAddrs(rnd, rules, 50)...) {
+			if ref.Lookup(addr) != buggy.Lookup(addr) {
+				return
+			}
+		}
+	}
+	t.Error("want generated inputs to detect a matcher which drops the first rule")
+}
+
+func TestRandomRules(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		rules := RandomRules(rnd, i)
+		if got, want := len(rules), i+2; got != want {
+			t.Fatalf("rule count mismatch, got=%d, want=%d", got, want)
+		}
+		for _, rule := range rules {
+			if p := rule.Target(); !p.IsValid() || p != p.Masked() {
+				t.Errorf("invalid or unmasked target, rule=%s", rule)
+			}
+		}
+		if got, want := rules[i].Target(), netip.MustParsePrefix("0.0.0.0/0"); got != want {
+			t.Errorf("IPv4 catch-all rule mismatch, got=%s, want=%s", got, want)
+		}
+		if got, want := rules[i+1].Target(), netip.MustParsePrefix("::/0"); got != want {
+			t.Errorf("IPv6 catch-all rule mismatch, got=%s, want=%s", got, want)
+		}
+	}
+}
+
+func TestBoundaryAddrs(t *testing.T) {
+	rules, err := ipacl.ParseRuleLines("deny 192.0.2.0/24\nallow 2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, addr := range BoundaryAddrs(rules) {
+		got = append(got, addr.String())
+	}
+	want := []string{
+		"0.0.0.0", "192.0.1.255", "192.0.2.0", "192.0.2.255", "192.0.3.0", "255.255.255.255",
+		"::", "2001:db7:ffff:ffff:ffff:ffff:ffff:ffff", "2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff",
+		"2001:db9::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("result mismatch, got=%v, want=%v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("result mismatch, i=%d, got=%s, want=%s", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package ipacltest
+
+import (
+	"net/netip"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+// LinearMatcher is a reference implementation of ipacl.Matcher which
+// checks the rules one by one. It is slow but obviously correct.
+type LinearMatcher struct {
+	rules []ipacl.Rule
+}
+
+// NewLinearMatcher creates a LinearMatcher.
+func NewLinearMatcher(rules []ipacl.Rule) *LinearMatcher {
+	return &LinearMatcher{rules: rules}
+}
+
+// Lookup returns the action of the first rule whose target contains ip.
+// The zone of ip is ignored, and an IPv4-mapped IPv6 address is looked up
+// as an IPv6 address. Host rules match no address. If no rule matches,
+// Lookup returns ipacl.Allow as ipacl.BinarySearch does for no rules.
+func (m *LinearMatcher) Lookup(ip netip.Addr) ipacl.Action {
+	ip = ip.WithZone("")
+	for _, rule := range m.rules {
+		if rule.Target().Contains(ip) {
+			return rule.Action()
+		}
+	}
+	return ipacl.Allow
+}
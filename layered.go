@@ -0,0 +1,99 @@
+package ipacl
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// Layer is a layer of a Layered access control list.
+// An address which is not matched by any rule in a layer passes through
+// to the next layer.
+type Layer struct {
+	// Name is the name of the layer.
+	Name string
+	// Rules is the rules of the layer.
+	Rules []Rule
+}
+
+// ParseLayer parses rules in multiple lines into a layer.
+// Unlike ParseRuleLines, no default rules are appended, so addresses not
+// matched by the rules pass through to the next layer.
+func ParseLayer(name, s string) (Layer, error) {
+	rules, err := parseRuleLines(s)
+	if err != nil {
+		return Layer{}, err
+	}
+	return Layer{Name: name, Rules: rules}, nil
+}
+
+// DefaultLayer is the layer index returned by Layered.Decide for addresses
+// which pass through all layers and get the default action.
+const DefaultLayer = -1
+
+// Layered is an access control list which consists of layers with explicit
+// precedence. The first layer which matches an address decides the action.
+type Layered struct {
+	layers []Layer
+	search BinarySearch
+
+	v4Ranges []layerRangeV4
+	v6Ranges []layerRangeV6
+}
+
+// NewLayered creates a Layered access control list from layers in
+// the order of precedence. defaultAction is used for addresses which pass
+// through all layers.
+// It panics if layers contain a host rule in the same way as NewBinarySearch.
+func NewLayered(layers []Layer, defaultAction Action) *Layered {
+	var rules []Rule
+	var ruleLayers []int
+	for i, layer := range layers {
+		for _, rule := range layer.Rules {
+			rules = append(rules, rule)
+			ruleLayers = append(ruleLayers, i)
+		}
+	}
+	rules = append(rules, NewRule(allIPv4CIDR, defaultAction), NewRule(allIPv6CIDR, defaultAction))
+	ruleLayers = append(ruleLayers, DefaultLayer, DefaultLayer)
+
+	return &Layered{
+		layers:   layers,
+		search:   NewBinarySearch(rules),
+		v4Ranges: newLayerRangesV4(rules, ruleLayers),
+		v6Ranges: newLayerRangesV6(rules, ruleLayers),
+	}
+}
+
+// Lookup lookups an IP address and returns the action defined in the access control list.
+func (l *Layered) Lookup(ip netip.Addr) Action {
+	return l.search.Lookup(ip)
+}
+
+// Decide returns the action for an IP address and the index of the layer
+// which decided it. The layer index is DefaultLayer if the address passed
+// through all layers.
+func (l *Layered) Decide(ip netip.Addr) (action Action, layer int) {
+	if ip.Is4() {
+		r := lookupLayerRangesV4(l.v4Ranges, v4AddrFromBytes(ip.As4()))
+		return r.action, r.layer
+	}
+	r := lookupLayerRangesV6(l.v6Ranges, v6AddrFromBytes(ip.As16()))
+	return r.action, r.layer
+}
+
+// BinarySearch returns the compiled access control list of all layers.
+func (l *Layered) BinarySearch() BinarySearch {
+	return l.search
+}
+
+// String returns the string representation of the ranges with the names of
+// the layers which decided them.
+func (l *Layered) String() string {
+	var b strings.Builder
+	b.WriteString("Layered{v4:[")
+	formatLayerRangesV4(&b, l.v4Ranges, l.layers)
+	b.WriteString("], v6:[")
+	formatLayerRangesV6(&b, l.v6Ranges, l.layers)
+	b.WriteString("]}")
+	return b.String()
+}
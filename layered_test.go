@@ -0,0 +1,65 @@
+package ipacl
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func mustParseLayer(t *testing.T, name, s string) Layer {
+	t.Helper()
+	layer, err := ParseLayer(name, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return layer
+}
+
+func TestLayered(t *testing.T) {
+	layers := []Layer{
+		mustParseLayer(t, "emergency", "deny 192.0.2.66\ndeny 2001:db8:bad::/48"),
+		mustParseLayer(t, "tenant", "allow 192.0.2.0/24\ndeny 198.51.100.0/24\nallow 2001:db8::/32"),
+		mustParseLayer(t, "service", "allow 198.51.100.0/23"),
+	}
+	l := NewLayered(layers, Deny)
+
+	testCases := []struct {
+		input      string
+		wantAction Action
+		wantLayer  int
+	}{
+		{input: "192.0.2.66", wantAction: Deny, wantLayer: 0},
+		{input: "192.0.2.65", wantAction: Allow, wantLayer: 1},
+		{input: "198.51.100.1", wantAction: Deny, wantLayer: 1},
+		{input: "198.51.101.1", wantAction: Allow, wantLayer: 2},
+		{input: "203.0.113.1", wantAction: Deny, wantLayer: DefaultLayer},
+		{input: "2001:db8:bad::1", wantAction: Deny, wantLayer: 0},
+		{input: "2001:db8::1", wantAction: Allow, wantLayer: 1},
+		{input: "::1", wantAction: Deny, wantLayer: DefaultLayer},
+	}
+	for _, tc := range testCases {
+		ip := netip.MustParseAddr(tc.input)
+		gotAction, gotLayer := l.Decide(ip)
+		if gotAction != tc.wantAction || gotLayer != tc.wantLayer {
+			t.Errorf("result mismatch, input=%s, gotAction=%s, gotLayer=%d, wantAction=%s, wantLayer=%d",
+				tc.input, gotAction, gotLayer, tc.wantAction, tc.wantLayer)
+		}
+		if got := l.Lookup(ip); got != tc.wantAction {
+			t.Errorf("lookup result mismatch, input=%s, got=%s, want=%s", tc.input, got, tc.wantAction)
+		}
+	}
+
+	const want = "Layered{v4:[!0.0.0.0-192.0.1.255(default), 192.0.2.0-192.0.2.65(tenant), !192.0.2.66(emergency), 192.0.2.67-192.0.2.255(tenant), !192.0.3.0-198.51.99.255(default), !198.51.100.0-198.51.100.255(tenant), 198.51.101.0-198.51.101.255(service), !198.51.102.0-255.255.255.255(default)], v6:[!::-2001:db7:ffff:ffff:ffff:ffff:ffff:ffff(default), 2001:db8::-2001:db8:bac:ffff:ffff:ffff:ffff:ffff(tenant), !2001:db8:bad::-2001:db8:bad:ffff:ffff:ffff:ffff:ffff(emergency), 2001:db8:bae::-2001:db8:ffff:ffff:ffff:ffff:ffff:ffff(tenant), !2001:db9::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff(default)]}"
+	if got := l.String(); got != want {
+		t.Errorf("string mismatch,\n got=%s\nwant=%s", got, want)
+	}
+}
+
+func TestParseLayer(t *testing.T) {
+	layer, err := ParseLayer("tenant", "allow 192.0.2.0/24 # comment\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Rules(layer.Rules).String(), "allow 192.0.2.0/24"; got != want {
+		t.Errorf("rules mismatch, got=%s, want=%s", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package ipacl
+
+//go:generate sh -c "./gen_rule_range_v6_go.sh"
+
+import (
+	"strings"
+)
+
+// layerRangeV4 is a range of addresses decided by a layer.
+type layerRangeV4 struct {
+	ipRange v4Range
+	action  Action
+	layer   int
+}
+
+// newLayerRangesV4 returns the ranges decided by each layer.
+// ruleLayers is the layer index of each rule in rules.
+// rules must have a rule for the whole address space.
+func newLayerRangesV4(rules []Rule, ruleLayers []int) []layerRangeV4 {
+	var ranges []layerRangeV4
+	for _, a := range attributeRulesV4(rules) {
+		layer, action := ruleLayers[a.rule], rules[a.rule].action
+		if n := len(ranges); n > 0 && ranges[n-1].layer == layer && ranges[n-1].action == action {
+			ranges[n-1].ipRange.end = a.ipRange.end
+		} else {
+			ranges = append(ranges, layerRangeV4{ipRange: a.ipRange, action: action, layer: layer})
+		}
+	}
+	return ranges
+}
+
+// lookupLayerRangesV4 returns the range which contains target.
+// ranges must cover the whole address space and be sorted in increasing order.
+func lookupLayerRangesV4(ranges []layerRangeV4, target v4Addr) layerRangeV4 {
+	i, _ := binarySearchNoDupFunc(ranges, target, func(e layerRangeV4, t v4Addr) int {
+		return e.ipRange.end.Compare(t)
+	})
+	return ranges[i]
+}
+
+func formatLayerRangesV4(b *strings.Builder, ranges []layerRangeV4, layers []Layer) {
+	for i, r := range ranges {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(ruleRangeV4{ipRange: r.ipRange, action: r.action}.String())
+		b.WriteByte('(')
+		if r.layer == DefaultLayer {
+			b.WriteString("default")
+		} else {
+			b.WriteString(layers[r.layer].Name)
+		}
+		b.WriteByte(')')
+	}
+}
@@ -0,0 +1,201 @@
+package ipacl
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+)
+
+// The mapped format of BinarySearch is designed to be memory-mapped and
+// searched in place:
+//
+//	magic       [4]byte  "IACM"
+//	version     uint16   mappedFormatVersion
+//	flags       uint16   bit 0: v4EvenIndexIsDeny, bit 1: v6EvenIndexIsDeny
+//	v4Count     uint32   number of IPv4 end addresses
+//	v6Count     uint32   number of IPv6 end addresses
+//	v4Offset    uint64   offset of IPv4 end addresses, always 32
+//	v6Offset    uint64   offset of IPv6 end addresses, aligned to 16 bytes
+//	v4EndAddrs  [v4Count][4]byte at v4Offset
+//	padding     zeros up to v6Offset
+//	v6EndAddrs  [v6Count][16]byte at v6Offset
+//
+// All integers and addresses are in big endian.
+const (
+	mappedFormatMagic   = "IACM"
+	mappedFormatVersion = 1
+
+	mappedHeaderLen = 32
+	mappedV6Align   = 16
+)
+
+// ErrInvalidLayout is returned by NewMappedBinarySearch, wrapped in a
+// *DecodeError, if the offsets in the header are not valid.
+var ErrInvalidLayout = errors.New("ipacl: invalid layout")
+
+// AppendMapped appends the mapped format of s to b.
+// The result can be written to a file, memory-mapped and passed to
+// NewMappedBinarySearch, so that processes share the page cache.
+func (s *BinarySearch) AppendMapped(b []byte) []byte {
+	v6EndAddrs := s.v6Ends()
+	v4Offset, v6Offset, size := mappedLayout(uint64(len(s.v4EndAddrs)), uint64(len(v6EndAddrs)))
+	start := len(b)
+
+	b = append(b, mappedFormatMagic...)
+	b = binary.BigEndian.AppendUint16(b, mappedFormatVersion)
+	var flags uint16
+	if s.v4EvenIndexIsDeny {
+		flags |= flagV4EvenIndexIsDeny
+	}
+	if s.v6EvenIndexIsDeny {
+		flags |= flagV6EvenIndexIsDeny
+	}
+	b = binary.BigEndian.AppendUint16(b, flags)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s.v4EndAddrs)))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(v6EndAddrs)))
+	b = binary.BigEndian.AppendUint64(b, v4Offset)
+	b = binary.BigEndian.AppendUint64(b, v6Offset)
+
+	for _, a := range s.v4EndAddrs {
+		b = binary.BigEndian.AppendUint32(b, uint32(a))
+	}
+	for uint64(len(b)-start) < v6Offset {
+		b = append(b, 0)
+	}
+	for _, a := range v6EndAddrs {
+		b = binary.BigEndian.AppendUint64(b, a.hi)
+		b = binary.BigEndian.AppendUint64(b, a.lo)
+	}
+	if uint64(len(b)-start) != size {
+		panic("ipacl: mapped format size mismatch")
+	}
+	return b
+}
+
+// mappedLayout returns the offsets of the end addresses and the total size
+// of the mapped format.
+func mappedLayout(v4Count, v6Count uint64) (v4Offset, v6Offset, size uint64) {
+	v4Offset = mappedHeaderLen
+	v6Offset = v4Offset + 4*v4Count
+	v6Offset = (v6Offset + mappedV6Align - 1) &^ (mappedV6Align - 1)
+	size = v6Offset + 16*v6Count
+	return v4Offset, v6Offset, size
+}
+
+// mappedFields returns the fields of the mapped format.
+func mappedFields(v4Count, v6Count uint64) []formatField {
+	v4Offset, v6Offset, _ := mappedLayout(v4Count, v6Count)
+	v4End := v4Offset + 4*v4Count
+	return []formatField{
+		{name: "magic", offset: 0, len: 4},
+		{name: "version", offset: 4, len: 2},
+		{name: "flags", offset: 6, len: 2},
+		{name: "v4Count", offset: 8, len: 4},
+		{name: "v6Count", offset: 12, len: 4},
+		{name: "v4Offset", offset: 16, len: 8},
+		{name: "v6Offset", offset: 24, len: 8},
+		{name: "v4EndAddrs", offset: v4Offset, len: 4 * v4Count},
+		{name: "padding", offset: v4End, len: v6Offset - v4End},
+		{name: "v6EndAddrs", offset: v6Offset, len: 16 * v6Count},
+	}
+}
+
+// MappedBinarySearch is a type for looking up an IP address in the access
+// control list in the mapped format without copying it.
+// The results of Lookup are same as BinarySearch.
+type MappedBinarySearch struct {
+	v4EndAddrs        []byte
+	v4EvenIndexIsDeny bool
+
+	v6EndAddrs        []byte
+	v6EvenIndexIsDeny bool
+}
+
+// NewMappedBinarySearch creates a MappedBinarySearch which refers to data
+// in the mapped format created by BinarySearch.AppendMapped.
+// data is validated but not copied, so it must not be modified or unmapped
+// while the returned value is used.
+// It returns a *DecodeError if data is corrupt or truncated.
+func NewMappedBinarySearch(data []byte) (*MappedBinarySearch, error) {
+	if len(data) < len(mappedFormatMagic) {
+		return nil, truncatedError(mappedFields(0, 0), uint64(len(data)))
+	}
+	if string(data[:len(mappedFormatMagic)]) != mappedFormatMagic {
+		return nil, &DecodeError{Offset: 0, Field: "magic", Err: ErrInvalidMagic}
+	}
+	if len(data) < mappedHeaderLen {
+		return nil, truncatedError(mappedFields(0, 0), uint64(len(data)))
+	}
+	if binary.BigEndian.Uint16(data[4:]) != mappedFormatVersion {
+		return nil, &DecodeError{Offset: 4, Field: "version", Err: ErrUnsupportedVersion}
+	}
+	flags := binary.BigEndian.Uint16(data[6:])
+	if flags&^knownFlags != 0 {
+		return nil, &DecodeError{Offset: 6, Field: "flags", Err: ErrInvalidFlags}
+	}
+	v4Count := uint64(binary.BigEndian.Uint32(data[8:]))
+	v6Count := uint64(binary.BigEndian.Uint32(data[12:]))
+	v4Offset, v6Offset, size := mappedLayout(v4Count, v6Count)
+	if binary.BigEndian.Uint64(data[16:]) != v4Offset {
+		return nil, &DecodeError{Offset: 16, Field: "v4Offset", Err: ErrInvalidLayout}
+	}
+	if binary.BigEndian.Uint64(data[24:]) != v6Offset {
+		return nil, &DecodeError{Offset: 24, Field: "v6Offset", Err: ErrInvalidLayout}
+	}
+	if uint64(len(data)) < size {
+		return nil, truncatedError(mappedFields(v4Count, v6Count), uint64(len(data)))
+	}
+	if uint64(len(data)) > size {
+		return nil, &DecodeError{Offset: size, Err: ErrTrailingData}
+	}
+	for i := v4Offset + 4*v4Count; i < v6Offset; i++ {
+		if data[i] != 0 {
+			return nil, &DecodeError{Offset: i, Field: "padding", Err: ErrInvalidLayout}
+		}
+	}
+
+	m := &MappedBinarySearch{
+		v4EndAddrs:        data[v4Offset : v4Offset+4*v4Count],
+		v4EvenIndexIsDeny: flags&flagV4EvenIndexIsDeny != 0,
+		v6EndAddrs:        data[v6Offset:size],
+		v6EvenIndexIsDeny: flags&flagV6EvenIndexIsDeny != 0,
+	}
+	for i := 1; i < m.v4Len(); i++ {
+		if m.v4EndAddr(i-1).Compare(m.v4EndAddr(i)) >= 0 {
+			return nil, &DecodeError{Offset: v4Offset + 4*uint64(i), Field: "v4EndAddrs", Err: ErrNotIncreasing}
+		}
+	}
+	for i := 1; i < m.v6Len(); i++ {
+		if m.v6EndAddr(i-1).Compare(m.v6EndAddr(i)) >= 0 {
+			return nil, &DecodeError{Offset: v6Offset + 16*uint64(i), Field: "v6EndAddrs", Err: ErrNotIncreasing}
+		}
+	}
+	return m, nil
+}
+
+func (m *MappedBinarySearch) v4Len() int {
+	return len(m.v4EndAddrs) / 4
+}
+
+func (m *MappedBinarySearch) v4EndAddr(i int) v4Addr {
+	return v4Addr(binary.BigEndian.Uint32(m.v4EndAddrs[4*i:]))
+}
+
+func (m *MappedBinarySearch) v6Len() int {
+	return len(m.v6EndAddrs) / 16
+}
+
+func (m *MappedBinarySearch) v6EndAddr(i int) v6Addr {
+	return v6Addr{
+		hi: binary.BigEndian.Uint64(m.v6EndAddrs[16*i:]),
+		lo: binary.BigEndian.Uint64(m.v6EndAddrs[16*i+8:]),
+	}
+}
+
+// Lookup lookups an IP address and returns the action defined in the access control list.
+func (m *MappedBinarySearch) Lookup(ip netip.Addr) Action {
+	if ip.Is4() {
+		return lookupEndAddrs(m.v4Len(), m.v4EndAddr, v4AddrFromBytes(ip.As4()), v4Addr.Compare, m.v4EvenIndexIsDeny)
+	}
+	return lookupEndAddrs(m.v6Len(), m.v6EndAddr, v6AddrFromBytes(ip.As16()), v6Addr.Compare, m.v6EvenIndexIsDeny)
+}
@@ -0,0 +1,132 @@
+package ipacl
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMappedBinarySearch_Lookup(t *testing.T) {
+	for i, rulesAndCases := range testRulesAndCasesData {
+		rules, err := ParseRuleLines(rulesAndCases.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewBinarySearch(rules)
+		m, err := NewMappedBinarySearch(s.AppendMapped(nil))
+		if err != nil {
+			t.Fatalf("want no error for rules %d, got: %s", i, err)
+		}
+		for _, tc := range rulesAndCases.cases {
+			got := m.Lookup(netip.MustParseAddr(tc.input))
+			if got != tc.want {
+				t.Errorf("result mismatch, rules=%d, input=%s, got=%s, want=%s", i, tc.input, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestMappedBinarySearch_file(t *testing.T) {
+	rules, err := ParseRuleLines("deny 192.0.2.1\nallow 2001:db8::/32\ndeny all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewBinarySearch(rules)
+	path := filepath.Join(t.TempDir(), "acl.bin")
+	if err := os.WriteFile(path, s.AppendMapped(nil), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMappedBinarySearch(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []string{"192.0.2.0", "192.0.2.1", "2001:db8::1", "2001:db9::"} {
+		ip := netip.MustParseAddr(input)
+		if got, want := m.Lookup(ip), s.Lookup(ip); got != want {
+			t.Errorf("result mismatch, input=%s, got=%s, want=%s", input, got, want)
+		}
+	}
+}
+
+func TestNewMappedBinarySearch_error(t *testing.T) {
+	rules, err := ParseRuleLines("deny 192.0.2.1\nallow 2001:db8::/32\ndeny all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewBinarySearch(rules)
+	valid := s.AppendMapped(nil)
+	if got, want := len(valid)%mappedV6Align, 0; got != want {
+		t.Errorf("size must be aligned, got remainder=%d", got)
+	}
+	modified := func(f func(b []byte)) []byte {
+		b := append([]byte(nil), valid...)
+		f(b)
+		return b
+	}
+
+	testCases := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "empty", data: nil, want: ErrTruncated},
+		{name: "magic", data: modified(func(b []byte) { b[0] = 'X' }), want: ErrInvalidMagic},
+		{name: "header", data: valid[:20], want: ErrTruncated},
+		{name: "version", data: modified(func(b []byte) { b[5] = 2 }), want: ErrUnsupportedVersion},
+		{name: "flags", data: modified(func(b []byte) { b[7] |= 0x80 }), want: ErrInvalidFlags},
+		{name: "offset", data: modified(func(b []byte) { b[31]++ }), want: ErrInvalidLayout},
+		{name: "padding", data: modified(func(b []byte) {
+			v4Offset, v6Offset, _ := mappedLayout(uint64(len(s.v4EndAddrs)), uint64(len(s.v6Ends())))
+			if v4End := v4Offset + 4*uint64(len(s.v4EndAddrs)); v4End < v6Offset {
+				b[v4End] = 1
+			}
+		}), want: ErrInvalidLayout},
+		{name: "truncated", data: valid[:len(valid)-1], want: ErrTruncated},
+		{name: "trailing", data: append(append([]byte(nil), valid...), 0), want: ErrTrailingData},
+		{name: "notIncreasing", data: modified(func(b []byte) {
+			_, v6Offset, _ := mappedLayout(uint64(len(s.v4EndAddrs)), uint64(len(s.v6Ends())))
+			copy(b[v6Offset+16:], b[v6Offset:v6Offset+16])
+		}), want: ErrNotIncreasing},
+	}
+	for _, tc := range testCases {
+		_, err := NewMappedBinarySearch(tc.data)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("error mismatch for %s, got=%v, want=%v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func FuzzMappedBinarySearch_Lookup(f *testing.F) {
+	f.Add(`
+		deny  192.168.1.1
+		allow 192.168.1.0/24
+		allow 10.1.1.0/16
+		allow 2001:0db8::/32
+		deny  all
+		`, "192.168.1.1")
+	f.Fuzz(func(t *testing.T, s, input string) {
+		rules, err := ParseRuleLines(s)
+		if err != nil || hasHostRule(rules) {
+			t.Skip()
+		}
+		target, err := netip.ParseAddr(input)
+		if err != nil || strings.Contains(target.String(), "%") {
+			t.Skip()
+		}
+		bs := NewBinarySearch(rules)
+		m, err := NewMappedBinarySearch(bs.AppendMapped(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := m.Lookup(target), bs.Lookup(target); got != want {
+			t.Errorf("result mismatch, got=%s, want=%s", got, want)
+		}
+	})
+}
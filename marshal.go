@@ -0,0 +1,192 @@
+package ipacl
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// The binary format of BinarySearch is:
+//
+//	magic       [4]byte  "IACL"
+//	version     uint16   binaryFormatVersion
+//	flags       uint16   bit 0: v4EvenIndexIsDeny, bit 1: v6EvenIndexIsDeny
+//	v4Count     uint32   number of IPv4 end addresses
+//	v6Count     uint32   number of IPv6 end addresses
+//	v4EndAddrs  [v4Count][4]byte
+//	v6EndAddrs  [v6Count][16]byte
+//	checksum    uint32   CRC-32 (IEEE) of all preceding bytes
+//
+// All integers and addresses are in big endian.
+const (
+	binaryFormatMagic   = "IACL"
+	binaryFormatVersion = 1
+
+	binaryHeaderLen   = 16
+	binaryChecksumLen = 4
+
+	flagV4EvenIndexIsDeny = 1 << 0
+	flagV6EvenIndexIsDeny = 1 << 1
+	knownFlags            = flagV4EvenIndexIsDeny | flagV6EvenIndexIsDeny
+)
+
+// Errors returned by BinarySearch.UnmarshalBinary.
+var (
+	ErrInvalidMagic       = errors.New("ipacl: invalid magic")
+	ErrUnsupportedVersion = errors.New("ipacl: unsupported version")
+	ErrInvalidFlags       = errors.New("ipacl: invalid flags")
+	ErrTruncated          = errors.New("ipacl: truncated data")
+	ErrTrailingData       = errors.New("ipacl: trailing data")
+	ErrChecksumMismatch   = errors.New("ipacl: checksum mismatch")
+	ErrNotIncreasing      = errors.New("ipacl: end addresses are not strictly increasing")
+)
+
+// DecodeError is the error returned by BinarySearch.UnmarshalBinary and
+// NewMappedBinarySearch. Err is one of the errors above, so DecodeError
+// can be checked with errors.Is.
+type DecodeError struct {
+	// Offset is the byte offset of Field in the data.
+	Offset uint64
+	// Field is the name of the invalid field, or empty if the error is
+	// about the data after the last field.
+	Field string
+	// Err is the cause of the error.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s at offset %d", e.Err, e.Offset)
+	}
+	return fmt.Sprintf("%s in %s at offset %d", e.Err, e.Field, e.Offset)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// formatField is a field in the binary or mapped format.
+type formatField struct {
+	name   string
+	offset uint64
+	len    uint64
+}
+
+// truncatedError returns the error for data of n bytes which ends in the
+// middle of fields. fields must be sorted by offset.
+func truncatedError(fields []formatField, n uint64) error {
+	for _, f := range fields {
+		if f.offset+f.len > n {
+			return &DecodeError{Offset: f.offset, Field: f.name, Err: ErrTruncated}
+		}
+	}
+	panic("ipacl: data is not truncated")
+}
+
+// binaryFields returns the fields of the binary format.
+func binaryFields(v4Count, v6Count uint64) []formatField {
+	v6Offset := binaryHeaderLen + 4*v4Count
+	checksumOffset := v6Offset + 16*v6Count
+	return []formatField{
+		{name: "magic", offset: 0, len: 4},
+		{name: "version", offset: 4, len: 2},
+		{name: "flags", offset: 6, len: 2},
+		{name: "v4Count", offset: 8, len: 4},
+		{name: "v6Count", offset: 12, len: 4},
+		{name: "v4EndAddrs", offset: binaryHeaderLen, len: 4 * v4Count},
+		{name: "v6EndAddrs", offset: v6Offset, len: 16 * v6Count},
+		{name: "checksum", offset: checksumOffset, len: binaryChecksumLen},
+	}
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (s *BinarySearch) MarshalBinary() ([]byte, error) {
+	v6EndAddrs := s.v6Ends()
+	size := binaryHeaderLen + 4*len(s.v4EndAddrs) + 16*len(v6EndAddrs) + binaryChecksumLen
+	b := make([]byte, 0, size)
+
+	b = append(b, binaryFormatMagic...)
+	b = binary.BigEndian.AppendUint16(b, binaryFormatVersion)
+	var flags uint16
+	if s.v4EvenIndexIsDeny {
+		flags |= flagV4EvenIndexIsDeny
+	}
+	if s.v6EvenIndexIsDeny {
+		flags |= flagV6EvenIndexIsDeny
+	}
+	b = binary.BigEndian.AppendUint16(b, flags)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s.v4EndAddrs)))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(v6EndAddrs)))
+
+	for _, a := range s.v4EndAddrs {
+		b = binary.BigEndian.AppendUint32(b, uint32(a))
+	}
+	for _, a := range v6EndAddrs {
+		b = binary.BigEndian.AppendUint64(b, a.hi)
+		b = binary.BigEndian.AppendUint64(b, a.lo)
+	}
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b)), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+// It returns a *DecodeError if data is corrupt or truncated, and s is not
+// modified in that case.
+func (s *BinarySearch) UnmarshalBinary(data []byte) error {
+	if len(data) < len(binaryFormatMagic) {
+		return truncatedError(binaryFields(0, 0), uint64(len(data)))
+	}
+	if string(data[:len(binaryFormatMagic)]) != binaryFormatMagic {
+		return &DecodeError{Offset: 0, Field: "magic", Err: ErrInvalidMagic}
+	}
+	if len(data) < binaryHeaderLen {
+		return truncatedError(binaryFields(0, 0), uint64(len(data)))
+	}
+	if binary.BigEndian.Uint16(data[4:]) != binaryFormatVersion {
+		return &DecodeError{Offset: 4, Field: "version", Err: ErrUnsupportedVersion}
+	}
+	flags := binary.BigEndian.Uint16(data[6:])
+	if flags&^knownFlags != 0 {
+		return &DecodeError{Offset: 6, Field: "flags", Err: ErrInvalidFlags}
+	}
+	v4Count := uint64(binary.BigEndian.Uint32(data[8:]))
+	v6Count := uint64(binary.BigEndian.Uint32(data[12:]))
+
+	size := binaryHeaderLen + 4*v4Count + 16*v6Count + binaryChecksumLen
+	if uint64(len(data)) < size {
+		return truncatedError(binaryFields(v4Count, v6Count), uint64(len(data)))
+	}
+	if uint64(len(data)) > size {
+		return &DecodeError{Offset: size, Err: ErrTrailingData}
+	}
+	body := data[:size-binaryChecksumLen]
+	if binary.BigEndian.Uint32(data[len(body):]) != crc32.ChecksumIEEE(body) {
+		return &DecodeError{Offset: uint64(len(body)), Field: "checksum", Err: ErrChecksumMismatch}
+	}
+
+	var t BinarySearch
+	t.v4EvenIndexIsDeny = flags&flagV4EvenIndexIsDeny != 0
+	t.v6EvenIndexIsDeny = flags&flagV6EvenIndexIsDeny != 0
+
+	p := body[binaryHeaderLen:]
+	t.v4EndAddrs = make([]v4Addr, v4Count)
+	for i := range t.v4EndAddrs {
+		t.v4EndAddrs[i] = v4Addr(binary.BigEndian.Uint32(p))
+		p = p[4:]
+		if i > 0 && t.v4EndAddrs[i-1].Compare(t.v4EndAddrs[i]) >= 0 {
+			return &DecodeError{Offset: binaryHeaderLen + 4*uint64(i), Field: "v4EndAddrs", Err: ErrNotIncreasing}
+		}
+	}
+	t.v6EndAddrs = make([]v6Addr, v6Count)
+	for i := range t.v6EndAddrs {
+		t.v6EndAddrs[i] = v6AddrFromBytes([16]byte(p))
+		p = p[16:]
+		if i > 0 && t.v6EndAddrs[i-1].Compare(t.v6EndAddrs[i]) >= 0 {
+			return &DecodeError{Offset: binaryHeaderLen + 4*v4Count + 16*uint64(i), Field: "v6EndAddrs", Err: ErrNotIncreasing}
+		}
+	}
+	t.compactV6()
+
+	*s = t
+	return nil
+}
@@ -0,0 +1,148 @@
+package ipacl
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+var _ encoding.BinaryMarshaler = (*BinarySearch)(nil)
+var _ encoding.BinaryUnmarshaler = (*BinarySearch)(nil)
+
+func TestBinarySearch_MarshalBinary(t *testing.T) {
+	for i, rulesAndCases := range testRulesAndCasesData {
+		rules, err := ParseRuleLines(rulesAndCases.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewBinarySearch(rules)
+		data, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got BinarySearch
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatalf("want no error for rules %d, got: %s", i, err)
+		}
+		if got.String() != s.String() {
+			t.Errorf("result mismatch for rules %d,\n got=%s\nwant=%s", i, got.String(), s.String())
+		}
+	}
+}
+
+func TestBinarySearch_UnmarshalBinary_error(t *testing.T) {
+	rules, err := ParseRuleLines("deny 192.0.2.1\nallow 2001:db8::/32\ndeny all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewBinarySearch(rules)
+	valid, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modified := func(f func(b []byte) []byte, fixChecksum bool) []byte {
+		b := f(append([]byte(nil), valid...))
+		if fixChecksum {
+			body := b[:len(b)-binaryChecksumLen]
+			binary.BigEndian.PutUint32(b[len(body):], crc32.ChecksumIEEE(body))
+		}
+		return b
+	}
+
+	testCases := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "empty", data: nil, want: ErrTruncated},
+		{name: "shortMagic", data: valid[:2], want: ErrTruncated},
+		{name: "magic", data: modified(func(b []byte) []byte { b[0] = 'X'; return b }, true), want: ErrInvalidMagic},
+		{name: "header", data: valid[:10], want: ErrTruncated},
+		{name: "version", data: modified(func(b []byte) []byte { b[5] = 2; return b }, true), want: ErrUnsupportedVersion},
+		{name: "flags", data: modified(func(b []byte) []byte { b[7] |= 0x80; return b }, true), want: ErrInvalidFlags},
+		{name: "truncated", data: valid[:len(valid)-1], want: ErrTruncated},
+		{name: "trailing", data: append(append([]byte(nil), valid...), 0), want: ErrTrailingData},
+		{name: "checksum", data: modified(func(b []byte) []byte { b[binaryHeaderLen] ^= 1; return b }, false), want: ErrChecksumMismatch},
+		{name: "notIncreasing", data: modified(func(b []byte) []byte {
+			copy(b[binaryHeaderLen+4:], b[binaryHeaderLen:binaryHeaderLen+4])
+			return b
+		}, true), want: ErrNotIncreasing},
+	}
+	for _, tc := range testCases {
+		got := NewBinarySearch(rules)
+		err := got.UnmarshalBinary(tc.data)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("error mismatch for %s, got=%v, want=%v", tc.name, err, tc.want)
+		}
+		if got.String() != s.String() {
+			t.Errorf("receiver must not be modified on error for %s", tc.name)
+		}
+	}
+}
+
+func FuzzBinarySearch_UnmarshalBinary(f *testing.F) {
+	rules, err := ParseRuleLines("deny 192.0.2.1\nallow 2001:db8::/32\ndeny all")
+	if err != nil {
+		f.Fatal(err)
+	}
+	s := NewBinarySearch(rules)
+	data, err := s.MarshalBinary()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var s BinarySearch
+		if err := s.UnmarshalBinary(data); err != nil {
+			t.Skip()
+		}
+		got, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(data) {
+			t.Errorf("round trip mismatch,\n got=%x\nwant=%x", got, data)
+		}
+	})
+}
+
+func TestDecodeError(t *testing.T) {
+	rules, err := ParseRuleLines("deny 192.0.2.1\nallow 2001:db8::/32\ndeny all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewBinarySearch(rules)
+	valid, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	notIncreasing := append([]byte(nil), valid...)
+	copy(notIncreasing[binaryHeaderLen+4:], notIncreasing[binaryHeaderLen:binaryHeaderLen+4])
+	body := notIncreasing[:len(notIncreasing)-binaryChecksumLen]
+	binary.BigEndian.PutUint32(notIncreasing[len(body):], crc32.ChecksumIEEE(body))
+
+	testCases := []struct {
+		data []byte
+		want string
+	}{
+		{data: valid[:2], want: "ipacl: truncated data in magic at offset 0"},
+		{data: valid[:10], want: "ipacl: truncated data in v4Count at offset 8"},
+		{data: valid[:len(valid)-1], want: "ipacl: truncated data in checksum at offset 68"},
+		{data: append(append([]byte(nil), valid...), 0), want: "ipacl: trailing data at offset 72"},
+		{data: notIncreasing, want: "ipacl: end addresses are not strictly increasing in v6EndAddrs at offset 36"},
+	}
+	for i, tc := range testCases {
+		var got BinarySearch
+		err := got.UnmarshalBinary(tc.data)
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Fatalf("want *DecodeError for test case %d, got: %v", i, err)
+		}
+		if got := err.Error(); got != tc.want {
+			t.Errorf("error message mismatch for test case %d, got: %s, want: %s", i, got, tc.want)
+		}
+	}
+}
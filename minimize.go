@@ -0,0 +1,17 @@
+package ipacl
+
+// MinimizeRules returns the minimal ordered list of CIDR rules which gives
+// the same result as rules for every address with BinarySearch.Lookup.
+//
+// Nested prefixes of different actions are used where they express ranges
+// more compactly than a flat cover, for example "deny 10.0.0.1/32" followed
+// by "allow 10.0.0.0/8". The returned rules end with the rules for the whole
+// IPv4 and IPv6 address spaces.
+// It panics if rules contain a host rule in the same way as NewBinarySearch.
+func MinimizeRules(rules []Rule) []Rule {
+	s := NewBinarySearch(rules)
+	var minimized []Rule
+	minimized = appendMinimalRulesV4(minimized, s.v4RuleRanges())
+	minimized = appendMinimalRulesV6(minimized, s.v6RuleRanges())
+	return minimized
+}
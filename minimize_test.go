@@ -0,0 +1,148 @@
+package ipacl
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"strings"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func TestMinimizeRules(t *testing.T) {
+	testCases := []struct {
+		rules string
+		want  string
+	}{
+		{
+			rules: "",
+			want:  "allow 0.0.0.0/0, allow ::/0",
+		},
+		{
+			rules: "deny 192.0.2.0/25\ndeny 192.0.2.128/25",
+			want:  "deny 192.0.2.0/24, allow 0.0.0.0/0, allow ::/0",
+		},
+		{
+			rules: "allow 10.0.0.0/8\ndeny 10.1.0.0/16\ndeny all",
+			want:  "allow 10.0.0.0/8, deny 0.0.0.0/0, deny ::/0",
+		},
+		{
+			rules: "deny 10.0.0.1\nallow 10.0.0.0/8\ndeny all",
+			want:  "deny 10.0.0.1/32, allow 10.0.0.0/8, deny 0.0.0.0/0, deny ::/0",
+		},
+		{
+			rules: "allow 10.0.0.0/9\nallow 10.128.0.0/10\nallow 10.192.0.0/11\nallow 10.224.0.0/12\ndeny all",
+			want:  "deny 10.240.0.0/12, allow 10.0.0.0/8, deny 0.0.0.0/0, deny ::/0",
+		},
+		{
+			rules: "deny 0.0.0.0/1\ndeny 128.0.0.0/1\nallow 2001:db8::/33\nallow 2001:db8:8000::/33\ndeny all",
+			want:  "deny 0.0.0.0/0, allow 2001:db8::/32, deny ::/0",
+		},
+	}
+	for i, tc := range testCases {
+		rules, err := ParseRuleLines(tc.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := MinimizeRules(rules)
+		if diff := gocmp.Diff(tc.want, Rules(got).String()); diff != "" {
+			t.Errorf("result mismatch for test case %d, (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+// randomRuleLines returns random rules whose targets are in base
+// with the prefix length base.Bits() or longer.
+func randomRuleLines(rnd *rand.Rand, base netip.Prefix, n int) string {
+	var b strings.Builder
+	hostBits := base.Addr().BitLen() - base.Bits()
+	for i := 0; i < n; i++ {
+		action := Allow
+		if rnd.Intn(2) == 0 {
+			action = Deny
+		}
+		addr := base.Addr()
+		for j := rnd.Intn(1 << hostBits); j > 0; j-- {
+			addr = addr.Next()
+		}
+		bits := base.Bits() + rnd.Intn(hostBits+1)
+		fmt.Fprintf(&b, "%s %s\n", action, netip.PrefixFrom(addr, bits).Masked())
+	}
+	if rnd.Intn(2) == 0 {
+		b.WriteString("deny all\n")
+	}
+	return b.String()
+}
+
+func TestMinimizeRules_Exhaustive(t *testing.T) {
+	bases := []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.0/27"),
+		netip.MustParsePrefix("2001:db8::/123"),
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		var b strings.Builder
+		for _, base := range bases {
+			b.WriteString(randomRuleLines(rnd, base, rnd.Intn(8)))
+		}
+		rules, err := ParseRuleLines(b.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		minimized := MinimizeRules(rules)
+		if len(minimized) > len(rules) {
+			t.Errorf("minimized rules must not be longer than original, rules=%s, minimized=%s", Rules(rules), Rules(minimized))
+		}
+
+		want := NewBinarySearch(rules)
+		got := NewBinarySearch(minimized)
+		for _, base := range bases {
+			// Check all addresses in base and its neighbors.
+			ip := base.Addr().Prev()
+			for j := 0; j < 1<<(base.Addr().BitLen()-base.Bits())+2; j++ {
+				if g, w := got.Lookup(ip), want.Lookup(ip); g != w {
+					t.Fatalf("result mismatch, rules=%s, minimized=%s, ip=%s, got=%s, want=%s",
+						Rules(rules), Rules(minimized), ip, g, w)
+				}
+				ip = ip.Next()
+			}
+		}
+	}
+}
+
+func FuzzMinimizeRules(f *testing.F) {
+	f.Add(`
+		deny  192.168.1.1
+		allow 192.168.1.0/24
+		allow 10.1.1.0/16
+		allow 2001:0db8::/32
+		deny  all
+		`, "192.168.1.1")
+	f.Add(`
+		deny  2001:db8::1
+		allow 2001:db8::/126
+		deny  2001:db8::/32
+		`, "2001:db8::2")
+	f.Fuzz(func(t *testing.T, s, input string) {
+		rules, err := ParseRuleLines(s)
+		if err != nil || hasHostRule(rules) {
+			t.Skip()
+		}
+		target, err := netip.ParseAddr(input)
+		if err != nil || strings.Contains(target.String(), "%") {
+			t.Skip()
+		}
+		minimized := MinimizeRules(rules)
+		want := NewBinarySearch(rules)
+		got := NewBinarySearch(minimized)
+		if g, w := got.Lookup(target), want.Lookup(target); g != w {
+			t.Errorf("result mismatch, rules=%s, minimized=%s, ip=%s, got=%s, want=%s",
+				Rules(rules), Rules(minimized), target, g, w)
+		}
+		if g, w := got.String(), want.String(); g != w {
+			t.Errorf("compiled ranges mismatch, rules=%s, minimized=%s,\n got=%s\nwant=%s",
+				Rules(rules), Rules(minimized), g, w)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package ipacl
+
+//go:generate sh -c "./gen_rule_range_v6_go.sh"
+
+// minimizeNodeV4 is a node of the binary trie over the address space
+// used to find the minimal prefix rules for ranges.
+type minimizeNodeV4 struct {
+	start v4Addr
+	bits  int
+
+	// action is the action of all addresses in the node if the node is uniform.
+	// It is zero if the node is not uniform.
+	action Action
+
+	left, right *minimizeNodeV4
+
+	// cost is the minimal number of rules in the subtree indexed by
+	// the action minus one of an enclosing rule.
+	cost [2]int
+}
+
+// newMinimizeNodeV4 builds the trie for the prefix of start with the prefix
+// length bits. ranges must cover the whole address space and be sorted in
+// increasing order.
+func newMinimizeNodeV4(ranges []ruleRangeV4, start v4Addr, bits int) *minimizeNodeV4 {
+	n := &minimizeNodeV4{start: start, bits: bits}
+	i, _ := binarySearchNoDupFunc(ranges, start, func(e ruleRangeV4, t v4Addr) int {
+		return e.ipRange.end.Compare(t)
+	})
+	if ranges[i].ipRange.end.Compare(start.LastInPrefix(bits)) >= 0 {
+		n.action = ranges[i].action
+		n.cost[n.action.Negated()-1] = 1
+		return n
+	}
+
+	// A node of a single address is always uniform, so bits is less than
+	// the bit length of an address here.
+	n.left = newMinimizeNodeV4(ranges, start, bits+1)
+	n.right = newMinimizeNodeV4(ranges, start.LastInPrefix(bits+1).Next(), bits+1)
+	for _, a := range []Action{Allow, Deny} {
+		n.cost[a-1] = min(n.childrenCost(a), 1+n.childrenCost(a.Negated()))
+	}
+	return n
+}
+
+func (n *minimizeNodeV4) childrenCost(enclosing Action) int {
+	return n.left.cost[enclosing-1] + n.right.cost[enclosing-1]
+}
+
+// appendRules appends the rules for the subtree to rules, given that
+// the addresses not matched by them get the action enclosing.
+// Rules for a child are appended before the rule for its parent, so that
+// more specific rules match first.
+func (n *minimizeNodeV4) appendRules(rules []Rule, enclosing Action) []Rule {
+	if n.action != 0 {
+		if n.action != enclosing {
+			rules = append(rules, NewRule(n.start.Prefix(n.bits), n.action))
+		}
+		return rules
+	}
+
+	negated := enclosing.Negated()
+	if 1+n.childrenCost(negated) < n.childrenCost(enclosing) {
+		rules = n.left.appendRules(rules, negated)
+		rules = n.right.appendRules(rules, negated)
+		return append(rules, NewRule(n.start.Prefix(n.bits), negated))
+	}
+	rules = n.left.appendRules(rules, enclosing)
+	return n.right.appendRules(rules, enclosing)
+}
+
+// appendMinimalRulesV4 appends the minimal prefix rules for ranges to rules.
+// ranges must cover the whole address space and be sorted in increasing order.
+// The appended rules always end with the rule for the whole address space.
+func appendMinimalRulesV4(rules []Rule, ranges []ruleRangeV4) []Rule {
+	var first v4Addr
+	root := newMinimizeNodeV4(ranges, first, 0)
+	if root.action != 0 {
+		return append(rules, NewRule(root.start.Prefix(0), root.action))
+	}
+
+	action := Allow
+	if root.childrenCost(Deny) < root.childrenCost(Allow) {
+		action = Deny
+	}
+	rules = root.left.appendRules(rules, action)
+	rules = root.right.appendRules(rules, action)
+	return append(rules, NewRule(root.start.Prefix(0), action))
+}
@@ -0,0 +1,86 @@
+package netacl
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"syscall"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+// ErrDialDenied is the error returned by the function made by DialControl
+// when the destination address is denied.
+var ErrDialDenied = errors.New("netacl: destination denied by access control list")
+
+// DialControl returns a function for net.Dialer.Control which denies
+// connecting to the destination addresses denied by m.
+//
+// Since the function is called with the resolved address just before
+// connecting, the check cannot be bypassed by DNS rebinding.
+// An IPv4-mapped IPv6 address is checked as an IPv4 address.
+// Connecting to an address which is not an IP address, such as a unix
+// socket path, is denied.
+func DialControl(m ipacl.Matcher) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		ap, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return fmt.Errorf("%w: %s %q is not an IP address", ErrDialDenied, network, address)
+		}
+		if m.Lookup(ap.Addr().Unmap()) != ipacl.Allow {
+			return fmt.Errorf("%w: %s %s", ErrDialDenied, network, address)
+		}
+		return nil
+	}
+}
+
+// ssrfDenyPrefixes is the list of prefixes denied by SSRFDenyRules.
+var ssrfDenyPrefixes = []string{
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // shared address space (CGNAT)
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local, including the metadata address 169.254.169.254
+	"172.16.0.0/12",  // private
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // private
+	"198.18.0.0/15",  // benchmarking
+	"224.0.0.0/4",    // multicast
+	"240.0.0.0/4",    // reserved, including the limited broadcast address
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"64:ff9b::/96",   // NAT64
+	"64:ff9b:1::/48", // local-use NAT64
+	"2002::/16",      // 6to4
+	"fc00::/7",       // unique local (ULA), including the metadata address fd00:ec2::254
+	"fe80::/10",      // link-local
+	"ff00::/8",       // multicast
+}
+
+// SSRFDenyRules returns the deny rules for the addresses which should not be
+// reachable from requests on behalf of users, such as webhooks, to prevent
+// server-side request forgery (SSRF). The rules cover the loopback, private,
+// link-local, CGNAT, benchmarking, multicast and reserved ranges, the cloud
+// metadata addresses, IPv6 unique local addresses and the NAT64 and 6to4
+// ranges, which can embed any of the IPv4 addresses above.
+//
+// The returned rules have no rule for the other addresses, so the caller
+// can put allow rules for exceptions before them and rules for the other
+// addresses after them. See DefaultSSRFPolicy.
+func SSRFDenyRules() []ipacl.Rule {
+	rules := make([]ipacl.Rule, len(ssrfDenyPrefixes))
+	for i, s := range ssrfDenyPrefixes {
+		rules[i] = ipacl.NewRule(netip.MustParsePrefix(s), ipacl.Deny)
+	}
+	return rules
+}
+
+// DefaultSSRFPolicy returns the access control list which denies the
+// addresses of SSRFDenyRules and allows the other addresses.
+func DefaultSSRFPolicy() *ipacl.BinarySearch {
+	rules := append(SSRFDenyRules(),
+		ipacl.NewRule(netip.MustParsePrefix("0.0.0.0/0"), ipacl.Allow),
+		ipacl.NewRule(netip.MustParsePrefix("::/0"), ipacl.Allow))
+	s := ipacl.NewBinarySearch(rules)
+	return &s
+}
@@ -0,0 +1,103 @@
+package netacl
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestDialControl_defaultSSRFPolicy(t *testing.T) {
+	control := DialControl(DefaultSSRFPolicy())
+	testCases := []struct {
+		network string
+		address string
+		wantErr bool
+	}{
+		{network: "tcp4", address: "127.0.0.1:80", wantErr: true},
+		{network: "tcp4", address: "0.0.0.0:80", wantErr: true},
+		{network: "tcp4", address: "10.1.2.3:443", wantErr: true},
+		{network: "tcp4", address: "100.64.0.1:443", wantErr: true},
+		{network: "tcp4", address: "169.254.169.254:80", wantErr: true},
+		{network: "tcp4", address: "172.31.255.255:443", wantErr: true},
+		{network: "tcp4", address: "192.168.0.1:443", wantErr: true},
+		{network: "udp4", address: "224.0.0.1:5353", wantErr: true},
+		{network: "udp4", address: "255.255.255.255:67", wantErr: true},
+		{network: "tcp6", address: "[::]:80", wantErr: true},
+		{network: "tcp6", address: "[::1]:80", wantErr: true},
+		{network: "tcp6", address: "[::ffff:127.0.0.1]:80", wantErr: true},
+		{network: "tcp6", address: "[::ffff:169.254.169.254]:80", wantErr: true},
+		{network: "tcp6", address: "[fd00:ec2::254]:80", wantErr: true},
+		{network: "tcp6", address: "[64:ff9b::a9fe:a9fe]:80", wantErr: true},
+		{network: "tcp6", address: "[64:ff9b::7f00:1]:80", wantErr: true},
+		{network: "tcp6", address: "[64:ff9b:1::a00:1]:80", wantErr: true},
+		{network: "tcp6", address: "[2002:a9fe:a9fe::]:80", wantErr: true},
+		{network: "tcp4", address: "192.0.0.170:80", wantErr: true},
+		{network: "tcp4", address: "198.19.255.255:80", wantErr: true},
+		{network: "tcp6", address: "[fe80::1%eth0]:80", wantErr: true},
+		{network: "tcp6", address: "[ff02::1]:80", wantErr: true},
+		{network: "unix", address: "/var/run/docker.sock", wantErr: true},
+		{network: "tcp4", address: "8.8.8.8:443", wantErr: false},
+		{network: "tcp4", address: "172.32.0.1:443", wantErr: false},
+		{network: "tcp4", address: "100.128.0.1:443", wantErr: false},
+		{network: "tcp4", address: "198.20.0.1:443", wantErr: false},
+		{network: "tcp6", address: "[2001:4860:4860::8888]:443", wantErr: false},
+		{network: "tcp6", address: "[::ffff:8.8.8.8]:443", wantErr: false},
+	}
+	for _, tc := range testCases {
+		err := control(tc.network, tc.address, nil)
+		if tc.wantErr {
+			if !errors.Is(err, ErrDialDenied) {
+				t.Errorf("error mismatch, network=%s, address=%s, got=%v, want=%v", tc.network, tc.address, err, ErrDialDenied)
+			}
+		} else if err != nil {
+			t.Errorf("want no error, network=%s, address=%s, got=%s", tc.network, tc.address, err)
+		}
+	}
+}
+
+func TestDialControl_dialer(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	d := net.Dialer{Control: DialControl(DefaultSSRFPolicy())}
+	if _, err := d.Dial("tcp", l.Addr().String()); !errors.Is(err, ErrDialDenied) {
+		t.Errorf("error mismatch, got=%v, want=%v", err, ErrDialDenied)
+	}
+
+	// Resolving a hostname does not bypass the check.
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	if _, err := d.Dial("tcp4", net.JoinHostPort("localhost", port)); !errors.Is(err, ErrDialDenied) {
+		t.Errorf("error mismatch for hostname, got=%v, want=%v", err, ErrDialDenied)
+	}
+
+	d = net.Dialer{Control: DialControl(newTestMatcher(t, "allow 127.0.0.1/32\ndeny all"))}
+	conn, err := d.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("want no error, got=%s", err)
+	}
+	conn.Close()
+}
+
+func TestSSRFDenyRules(t *testing.T) {
+	rules := SSRFDenyRules()
+	if got, want := len(rules), len(ssrfDenyPrefixes); got != want {
+		t.Fatalf("rule count mismatch, got=%d, want=%d", got, want)
+	}
+	// The returned rules must be independent of the package state.
+	rules[0] = rules[1]
+	if got, want := SSRFDenyRules()[0].Target().String(), ssrfDenyPrefixes[0]; got != want {
+		t.Errorf("rule mismatch, got=%s, want=%s", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+package netacl
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+// DefaultMaxTarpitted is the maximum number of denied connections held by
+// the tarpit at the same time used when Options.MaxTarpitted is zero.
+const DefaultMaxTarpitted = 1024
+
+// Options is the options for NewListener.
+type Options struct {
+	// Tarpit is the delay before closing a denied connection. If zero,
+	// denied connections are closed immediately. The delay does not
+	// block Accept.
+	Tarpit time.Duration
+
+	// MaxTarpitted is the maximum number of denied connections held by
+	// the tarpit at the same time, so that denied clients cannot exhaust
+	// file descriptors. Denied connections over the limit are closed
+	// immediately. If zero, DefaultMaxTarpitted is used.
+	MaxTarpitted int
+
+	// OnDeny is called with a denied connection before it is closed,
+	// if not nil.
+	OnDeny func(conn net.Conn)
+
+	// NonIPAction is the action for connections whose remote address is
+	// not an IP address, such as unix socket connections.
+	// If zero, ipacl.Allow is used for unix socket connections and
+	// ipacl.Deny is used for the others, so that a connection whose
+	// remote address is of an unknown type does not bypass m.
+	NonIPAction ipacl.Action
+}
+
+type listener struct {
+	net.Listener
+	m         ipacl.Matcher
+	opts      Options
+	tarpitted atomic.Int64
+}
+
+// NewListener returns a listener which applies m to the remote addresses of
+// the connections accepted by inner. Denied connections are closed before
+// any bytes are read, and never returned by Accept.
+func NewListener(inner net.Listener, m ipacl.Matcher, opts Options) net.Listener {
+	return &listener{Listener: inner, m: m, opts: opts}
+}
+
+// Accept waits for and returns the next allowed connection.
+func (l *listener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.opts.check(l.m, conn, &l.tarpitted) {
+			return conn, nil
+		}
+	}
+}
+
+// check applies m to the remote address of conn and reports whether conn
+// is allowed. A denied connection is reported to OnDeny and closed after
+// the tarpit delay. tarpitted is the number of connections in the tarpit.
+func (o *Options) check(m ipacl.Matcher, conn net.Conn, tarpitted *atomic.Int64) bool {
+	action := o.NonIPAction
+	if ip, ok := addrIP(conn.RemoteAddr()); ok {
+		action = m.Lookup(ip)
+	} else if action == 0 {
+		action = ipacl.Deny
+		if addr := conn.RemoteAddr(); addr != nil && isUnixAddr(addr) {
+			action = ipacl.Allow
+		}
+	}
+	if action == ipacl.Allow {
+		return true
+	}
+
+	if o.OnDeny != nil {
+		o.OnDeny(conn)
+	}
+	if o.Tarpit > 0 {
+		maxTarpitted := int64(o.MaxTarpitted)
+		if maxTarpitted == 0 {
+			maxTarpitted = DefaultMaxTarpitted
+		}
+		if tarpitted.Add(1) <= maxTarpitted {
+			time.AfterFunc(o.Tarpit, func() {
+				conn.Close()
+				tarpitted.Add(-1)
+			})
+			return false
+		}
+		tarpitted.Add(-1)
+	}
+	conn.Close()
+	return false
+}
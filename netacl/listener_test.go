@@ -0,0 +1,245 @@
+package netacl
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+func dialFrom(t *testing.T, local net.Addr, remote net.Addr) net.Conn {
+	t.Helper()
+	d := net.Dialer{LocalAddr: local}
+	conn, err := d.Dial(remote.Network(), remote.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestListener(t *testing.T) {
+	testCases := []struct {
+		name      string
+		network   string
+		address   string
+		rules     string
+		denyFrom  string
+		allowFrom string
+	}{
+		{name: "ipv4", network: "tcp4", address: "127.0.0.1:0", rules: "deny 127.0.0.2/32\nallow all", denyFrom: "127.0.0.2", allowFrom: "127.0.0.1"},
+		{name: "ipv6", network: "tcp6", address: "[::1]:0", rules: "deny ::1/128\nallow all"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inner, err := net.Listen(tc.network, tc.address)
+			if err != nil {
+				t.Skipf("cannot listen on %s: %s", tc.address, err)
+			}
+			defer inner.Close()
+
+			var mu sync.Mutex
+			var denied []net.Addr
+			l := NewListener(inner, newTestMatcher(t, tc.rules), Options{
+				OnDeny: func(conn net.Conn) {
+					mu.Lock()
+					defer mu.Unlock()
+					denied = append(denied, conn.RemoteAddr())
+				},
+			})
+			ch := acceptOne(t, l)
+
+			var denyLocal, allowLocal net.Addr
+			if tc.denyFrom != "" {
+				denyLocal = &net.TCPAddr{IP: net.ParseIP(tc.denyFrom)}
+				allowLocal = &net.TCPAddr{IP: net.ParseIP(tc.allowFrom)}
+			}
+			deniedConn := dialFrom(t, denyLocal, l.Addr())
+			waitClosed(t, deniedConn)
+			select {
+			case conn := <-ch:
+				t.Fatalf("denied connection is accepted, remote=%v", conn)
+			default:
+			}
+			mu.Lock()
+			if got, want := len(denied), 1; got != want {
+				t.Errorf("denied count mismatch, got=%d, want=%d", got, want)
+			}
+			mu.Unlock()
+
+			if allowLocal == nil {
+				return
+			}
+			dialFrom(t, allowLocal, l.Addr())
+			conn := <-ch
+			if conn == nil {
+				t.Fatal("accept failed")
+			}
+			defer conn.Close()
+			if got, want := conn.RemoteAddr().(*net.TCPAddr).IP.String(), tc.allowFrom; got != want {
+				t.Errorf("remote address mismatch, got=%s, want=%s", got, want)
+			}
+		})
+	}
+}
+
+func TestListener_unix(t *testing.T) {
+	testCases := []struct {
+		name        string
+		nonIPAction ipacl.Action
+		wantAccept  bool
+	}{
+		{name: "default", wantAccept: true},
+		{name: "allow", nonIPAction: ipacl.Allow, wantAccept: true},
+		{name: "deny", nonIPAction: ipacl.Deny, wantAccept: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inner, err := net.Listen("unix", filepath.Join(t.TempDir(), "sock"))
+			if err != nil {
+				t.Skipf("cannot listen on unix socket: %s", err)
+			}
+			defer inner.Close()
+
+			l := NewListener(inner, newTestMatcher(t, "deny all"), Options{NonIPAction: tc.nonIPAction})
+			ch := acceptOne(t, l)
+			client := dialFrom(t, nil, l.Addr())
+			if !tc.wantAccept {
+				waitClosed(t, client)
+				return
+			}
+			conn := <-ch
+			if conn == nil {
+				t.Fatal("accept failed")
+			}
+			conn.Close()
+		})
+	}
+}
+
+// testAddr is a net.Addr of a type which addrIP does not know.
+type testAddr struct {
+	network string
+	address string
+}
+
+func (a testAddr) Network() string { return a.network }
+
+func (a testAddr) String() string { return a.address }
+
+// testAddrConn is a net.Conn whose remote address is a testAddr.
+type testAddrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *testAddrConn) RemoteAddr() net.Addr { return c.remote }
+
+func TestOptions_check_addrType(t *testing.T) {
+	testCases := []struct {
+		name        string
+		remote      net.Addr
+		nonIPAction ipacl.Action
+		want        bool
+	}{
+		{name: "allowedIP", remote: testAddr{network: "quic", address: "198.51.100.1:443"}, want: true},
+		{name: "deniedIP", remote: testAddr{network: "quic", address: "192.0.2.1:443"}, want: false},
+		{name: "deniedMappedIP", remote: testAddr{network: "quic", address: "[::ffff:192.0.2.1]:443"}, want: false},
+		{name: "unknown", remote: testAddr{network: "quic", address: "peer-1"}, want: false},
+		{name: "unknownAllowed", remote: testAddr{network: "quic", address: "peer-1"}, nonIPAction: ipacl.Allow, want: true},
+		{name: "nil", remote: nil, want: false},
+		{name: "unix", remote: testAddr{network: "unix", address: "/run/app.sock"}, want: true},
+		{name: "unixDenied", remote: testAddr{network: "unix", address: "/run/app.sock"}, nonIPAction: ipacl.Deny, want: false},
+	}
+	m := newTestMatcher(t, "deny 192.0.2.0/24\nallow all")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+
+			var tarpitted atomic.Int64
+			o := Options{NonIPAction: tc.nonIPAction}
+			conn := &testAddrConn{Conn: server, remote: tc.remote}
+			if got := o.check(m, conn, &tarpitted); got != tc.want {
+				t.Errorf("result mismatch, got=%v, want=%v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestListener_tarpit(t *testing.T) {
+	inner, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+
+	const tarpit = 200 * time.Millisecond
+	l := NewListener(inner, newTestMatcher(t, "deny 127.0.0.2/32\nallow all"), Options{Tarpit: tarpit})
+	ch := acceptOne(t, l)
+
+	start := time.Now()
+	deniedConn := dialFrom(t, &net.TCPAddr{IP: net.ParseIP("127.0.0.2")}, l.Addr())
+
+	// The tarpit delay must not block accepting the next connection.
+	dialFrom(t, &net.TCPAddr{IP: net.ParseIP("127.0.0.1")}, l.Addr())
+	conn := <-ch
+	if conn == nil {
+		t.Fatal("accept failed")
+	}
+	conn.Close()
+	if elapsed := time.Since(start); elapsed >= tarpit {
+		t.Errorf("accept blocked by tarpit, elapsed=%s", elapsed)
+	}
+
+	deniedConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadAll(deniedConn); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < tarpit {
+		t.Errorf("denied connection closed before tarpit delay, elapsed=%s", elapsed)
+	}
+}
+
+func TestListener_maxTarpitted(t *testing.T) {
+	inner, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+
+	const tarpit = time.Minute
+	l := NewListener(inner, newTestMatcher(t, "deny 127.0.0.2/32\nallow all"), Options{Tarpit: tarpit, MaxTarpitted: 2})
+	ch := acceptOne(t, l)
+
+	// The first two denied connections are held in the tarpit and the
+	// third one is closed immediately.
+	var deniedConns []net.Conn
+	for i := 0; i < 3; i++ {
+		deniedConns = append(deniedConns, dialFrom(t, &net.TCPAddr{IP: net.ParseIP("127.0.0.2")}, l.Addr()))
+	}
+	dialFrom(t, &net.TCPAddr{IP: net.ParseIP("127.0.0.1")}, l.Addr())
+	conn := <-ch
+	if conn == nil {
+		t.Fatal("accept failed")
+	}
+	conn.Close()
+
+	waitClosed(t, deniedConns[2])
+	for i, c := range deniedConns[:2] {
+		c.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		_, err := c.Read(make([]byte, 1))
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			t.Errorf("denied connection %d must be held in the tarpit, err=%v", i, err)
+		}
+	}
+	if got, want := l.(*listener).tarpitted.Load(), int64(2); got != want {
+		t.Errorf("tarpitted count mismatch, got=%d, want=%d", got, want)
+	}
+}
@@ -0,0 +1,386 @@
+// Package netacl provides net.Listener wrappers for access control by
+// a peer's IP address with ipacl.
+package netacl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+// DefaultProxyHeaderTimeout is the timeout for reading a PROXY protocol
+// header used when ProxyOptions.HeaderTimeout is zero.
+const DefaultProxyHeaderTimeout = 5 * time.Second
+
+// ErrInvalidProxyHeader is the error for a connection from a trusted
+// upstream which does not start with a valid PROXY protocol header.
+var ErrInvalidProxyHeader = errors.New("netacl: invalid PROXY protocol header")
+
+// ProxyOptions is the options for NewProxyListener.
+type ProxyOptions struct {
+	// Options is the options applied to the client addresses in the same
+	// way as NewListener. The RemoteAddr of a connection passed to OnDeny
+	// is the client address.
+	Options
+
+	// Trusted is the rules for the upstreams which are allowed to send
+	// PROXY protocol headers. Connections from the addresses allowed by
+	// Trusted must start with a header. The addresses which no rule in
+	// Trusted matches are not trusted, so Trusted should be parsed with
+	// ipacl.ParseLayer rather than ipacl.ParseRuleLines, which appends
+	// the rules to allow all addresses. If empty, no upstream is trusted.
+	Trusted []ipacl.Rule
+
+	// HeaderTimeout is the timeout for reading a header.
+	// If zero, DefaultProxyHeaderTimeout is used.
+	HeaderTimeout time.Duration
+
+	// OnError is called with a connection from a trusted upstream whose
+	// header cannot be read before it is closed, if not nil.
+	OnError func(conn net.Conn, err error)
+}
+
+type proxyListener struct {
+	net.Listener
+	m       ipacl.Matcher
+	trusted *ipacl.Layered
+	opts    ProxyOptions
+
+	tarpitted atomic.Int64
+
+	startOnce sync.Once
+	closeOnce sync.Once
+	// results receives the connections and errors which Accept returns.
+	results chan acceptResult
+	// done is closed when the listener is closed.
+	done chan struct{}
+}
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// NewProxyListener returns a listener which reads PROXY protocol version 1
+// and 2 headers from trusted upstreams and applies m to the client
+// addresses.
+//
+// The RemoteAddr and LocalAddr of a connection returned by Accept are the
+// source and destination addresses in the header. If the header is for
+// an unknown or local connection, such as a health check, they are the
+// addresses of the connection to the upstream. For a connection from
+// an upstream which is not trusted, the header is not read and m is applied
+// to the address of the upstream.
+//
+// Denied connections and connections with invalid headers are closed and
+// never returned by Accept. The header of each connection is read in its
+// own goroutine, so a trusted upstream which does not send a header does
+// not block other connections, and OnDeny and OnError may be called
+// concurrently. Connections whose remote address is not an IP address,
+// such as unix socket connections, are not trusted and handled with
+// opts.NonIPAction.
+//
+// The connections of the inner listener are accepted in a goroutine started
+// by the first call of Accept, which stops when the returned listener is
+// closed. It panics if opts.Trusted contains a host rule in the same way as
+// ipacl.NewBinarySearch.
+func NewProxyListener(inner net.Listener, m ipacl.Matcher, opts ProxyOptions) net.Listener {
+	if opts.HeaderTimeout == 0 {
+		opts.HeaderTimeout = DefaultProxyHeaderTimeout
+	}
+	return &proxyListener{
+		Listener: inner,
+		m:        m,
+		trusted:  ipacl.NewLayered([]ipacl.Layer{{Rules: opts.Trusted}}, ipacl.Deny),
+		opts:     opts,
+		results:  make(chan acceptResult),
+		done:     make(chan struct{}),
+	}
+}
+
+// Accept waits for and returns the next allowed connection.
+func (l *proxyListener) Accept() (net.Conn, error) {
+	l.startOnce.Do(func() { go l.acceptLoop() })
+	select {
+	case r := <-l.results:
+		return r.conn, r.err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes the listener. The connections whose headers are being read
+// are closed after the headers are read or HeaderTimeout elapses.
+func (l *proxyListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// acceptLoop accepts connections of the inner listener until it is closed.
+// Since results is unbuffered, errors are passed to Accept at the pace
+// Accept is called.
+func (l *proxyListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			if !l.send(acceptResult{err: err}) || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		peer, ok := addrIP(conn.RemoteAddr())
+		if ok && l.trusted.Lookup(peer) == ipacl.Allow {
+			go l.handleTrusted(conn)
+		} else if l.opts.check(l.m, conn, &l.tarpitted) && !l.send(acceptResult{conn: conn}) {
+			conn.Close()
+			return
+		}
+	}
+}
+
+// handleTrusted reads the header of a connection from a trusted upstream
+// and passes the connection to Accept if it is allowed.
+func (l *proxyListener) handleTrusted(conn net.Conn) {
+	conn, err := l.readHeader(conn)
+	if err != nil {
+		if l.opts.OnError != nil {
+			l.opts.OnError(conn, err)
+		}
+		conn.Close()
+		return
+	}
+	if l.opts.check(l.m, conn, &l.tarpitted) && !l.send(acceptResult{conn: conn}) {
+		conn.Close()
+	}
+}
+
+// send passes r to Accept and reports whether it is passed before the
+// listener is closed.
+func (l *proxyListener) send(r acceptResult) bool {
+	select {
+	case l.results <- r:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+func (l *proxyListener) readHeader(conn net.Conn) (net.Conn, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(l.opts.HeaderTimeout)); err != nil {
+		return conn, err
+	}
+	h, err := readProxyHeader(conn)
+	if err != nil {
+		return conn, err
+	}
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return conn, err
+	}
+	if !h.src.IsValid() {
+		return conn, nil
+	}
+	return &proxyConn{
+		Conn:   conn,
+		remote: net.TCPAddrFromAddrPort(h.src),
+		local:  net.TCPAddrFromAddrPort(h.dst),
+	}, nil
+}
+
+// proxyConn is a connection with the addresses in a PROXY protocol header.
+type proxyConn struct {
+	net.Conn
+	remote net.Addr
+	local  net.Addr
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *proxyConn) LocalAddr() net.Addr { return c.local }
+
+// addrIP returns the IP address of addr if it is an IP network address.
+// An address of an unknown type is parsed from its string representation
+// unless it is a unix socket address.
+// An IPv4-mapped IPv6 address is converted to an IPv4 address.
+func addrIP(addr net.Addr) (netip.Addr, bool) {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.IPAddr:
+		ip = a.IP
+	default:
+		if addr == nil || isUnixAddr(addr) {
+			return netip.Addr{}, false
+		}
+		ap, err := netip.ParseAddrPort(addr.String())
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		return ap.Addr().Unmap(), true
+	}
+	ipAddr, ok := netip.AddrFromSlice(ip)
+	return ipAddr.Unmap(), ok
+}
+
+// isUnixAddr reports whether addr is a unix socket address.
+func isUnixAddr(addr net.Addr) bool {
+	switch addr.Network() {
+	case "unix", "unixgram", "unixpacket":
+		return true
+	default:
+		return false
+	}
+}
+
+// proxyHeader is the addresses in a PROXY protocol header.
+// src and dst are invalid for an unknown or local connection.
+type proxyHeader struct {
+	src netip.AddrPort
+	dst netip.AddrPort
+}
+
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	// proxyV1MaxLen is the maximum length of a version 1 header including CRLF.
+	proxyV1MaxLen = 107
+	// proxyV2HeaderLen is the length of the fixed part of a version 2 header.
+	proxyV2HeaderLen = 16
+)
+
+// readProxyHeader reads a PROXY protocol header of version 1 or 2 from r.
+// It does not read any bytes after the header.
+func readProxyHeader(r io.Reader) (proxyHeader, error) {
+	// The shortest header "PROXY UNKNOWN\r\n" is longer than the signature
+	// of version 2, so reading the signature length never reads too much.
+	buf := make([]byte, len(proxyV2Signature), proxyV1MaxLen)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return proxyHeader{}, fmt.Errorf("%w: %w", ErrInvalidProxyHeader, err)
+	}
+	if bytes.Equal(buf, proxyV2Signature) {
+		return readProxyHeaderV2(r)
+	}
+	if !bytes.HasPrefix(buf, []byte("PROXY ")) {
+		return proxyHeader{}, fmt.Errorf("%w: no PROXY protocol signature", ErrInvalidProxyHeader)
+	}
+	for !bytes.HasSuffix(buf, []byte("\r\n")) {
+		if len(buf) == proxyV1MaxLen {
+			return proxyHeader{}, fmt.Errorf("%w: version 1 header too long", ErrInvalidProxyHeader)
+		}
+		var b [1]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return proxyHeader{}, fmt.Errorf("%w: %w", ErrInvalidProxyHeader, err)
+		}
+		buf = append(buf, b[0])
+	}
+	return parseProxyHeaderV1(string(buf[:len(buf)-2]))
+}
+
+// parseProxyHeaderV1 parses a version 1 header line without CRLF.
+func parseProxyHeaderV1(line string) (proxyHeader, error) {
+	fields := strings.Split(line, " ")
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return proxyHeader{}, fmt.Errorf("%w: invalid version 1 header", ErrInvalidProxyHeader)
+	}
+	switch fields[1] {
+	case "UNKNOWN":
+		// The rest of the line must be ignored.
+		return proxyHeader{}, nil
+	case "TCP4", "TCP6":
+	default:
+		return proxyHeader{}, fmt.Errorf("%w: unsupported protocol %q", ErrInvalidProxyHeader, fields[1])
+	}
+	if len(fields) != 6 {
+		return proxyHeader{}, fmt.Errorf("%w: invalid version 1 header", ErrInvalidProxyHeader)
+	}
+	srcIP, err1 := netip.ParseAddr(fields[2])
+	dstIP, err2 := netip.ParseAddr(fields[3])
+	srcPort, err3 := parseProxyPort(fields[4])
+	dstPort, err4 := parseProxyPort(fields[5])
+	if err := errors.Join(err1, err2, err3, err4); err != nil {
+		return proxyHeader{}, fmt.Errorf("%w: %w", ErrInvalidProxyHeader, err)
+	}
+	is4 := fields[1] == "TCP4"
+	if srcIP.Is4() != is4 || dstIP.Is4() != is4 || srcIP.Zone() != "" || dstIP.Zone() != "" {
+		return proxyHeader{}, fmt.Errorf("%w: address family mismatch", ErrInvalidProxyHeader)
+	}
+	return proxyHeader{
+		src: netip.AddrPortFrom(srcIP, srcPort),
+		dst: netip.AddrPortFrom(dstIP, dstPort),
+	}, nil
+}
+
+func parseProxyPort(s string) (uint16, error) {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	port, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return uint16(port), nil
+}
+
+// readProxyHeaderV2 reads the rest of a version 2 header after the signature.
+func readProxyHeaderV2(r io.Reader) (proxyHeader, error) {
+	var fixed [proxyV2HeaderLen - 12]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return proxyHeader{}, fmt.Errorf("%w: %w", ErrInvalidProxyHeader, err)
+	}
+	verCmd, famProto := fixed[0], fixed[1]
+	if verCmd>>4 != 2 {
+		return proxyHeader{}, fmt.Errorf("%w: unsupported version %d", ErrInvalidProxyHeader, verCmd>>4)
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(fixed[2:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return proxyHeader{}, fmt.Errorf("%w: %w", ErrInvalidProxyHeader, err)
+	}
+	switch verCmd & 0xf {
+	case 0x0:
+		// LOCAL command: the connection was established by the upstream itself.
+		return proxyHeader{}, nil
+	case 0x1:
+		// PROXY command.
+	default:
+		return proxyHeader{}, fmt.Errorf("%w: unsupported command %d", ErrInvalidProxyHeader, verCmd&0xf)
+	}
+
+	var addrLen int
+	switch famProto >> 4 {
+	case 0x1:
+		addrLen = 4
+	case 0x2:
+		addrLen = 16
+	default:
+		// AF_UNSPEC or AF_UNIX, which must be treated like UNKNOWN.
+		return proxyHeader{}, nil
+	}
+	if famProto&0xf != 0x1 {
+		// Only STREAM is supported since the listener accepts streams.
+		return proxyHeader{}, fmt.Errorf("%w: unsupported transport %d", ErrInvalidProxyHeader, famProto&0xf)
+	}
+	if len(payload) < 2*addrLen+4 {
+		return proxyHeader{}, fmt.Errorf("%w: address block too short", ErrInvalidProxyHeader)
+	}
+	srcIP, _ := netip.AddrFromSlice(payload[:addrLen])
+	dstIP, _ := netip.AddrFromSlice(payload[addrLen : 2*addrLen])
+	ports := payload[2*addrLen:]
+	return proxyHeader{
+		src: netip.AddrPortFrom(srcIP.Unmap(), binary.BigEndian.Uint16(ports)),
+		dst: netip.AddrPortFrom(dstIP.Unmap(), binary.BigEndian.Uint16(ports[2:])),
+	}, nil
+}
@@ -0,0 +1,362 @@
+package netacl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ipacl "github.com/hnakamur/ipacl-go"
+)
+
+func newTestMatcher(t testing.TB, s string) ipacl.Matcher {
+	t.Helper()
+	rules, err := ipacl.ParseRuleLines(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := ipacl.NewBinarySearch(rules)
+	return &bs
+}
+
+func newTestTrusted(t testing.TB, s string) []ipacl.Rule {
+	t.Helper()
+	layer, err := ipacl.ParseLayer("trusted", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return layer.Rules
+}
+
+func proxyV2Header(cmd, fam byte, payload []byte) []byte {
+	b := append([]byte(nil), proxyV2Signature...)
+	b = append(b, 0x20|cmd, fam)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(payload)))
+	return append(b, payload...)
+}
+
+func proxyV2Addrs(src, dst netip.AddrPort) []byte {
+	b := append(src.Addr().AsSlice(), dst.Addr().AsSlice()...)
+	b = binary.BigEndian.AppendUint16(b, src.Port())
+	return binary.BigEndian.AppendUint16(b, dst.Port())
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	src4 := netip.MustParseAddrPort("192.0.2.1:56324")
+	dst4 := netip.MustParseAddrPort("198.51.100.1:443")
+	src6 := netip.MustParseAddrPort("[2001:db8::1]:56324")
+	dst6 := netip.MustParseAddrPort("[2001:db8::2]:443")
+	testCases := []struct {
+		name    string
+		input   []byte
+		wantSrc string
+		wantDst string
+		wantErr bool
+	}{
+		{name: "v1TCP4", input: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"), wantSrc: "192.0.2.1:56324", wantDst: "198.51.100.1:443"},
+		{name: "v1TCP6", input: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"), wantSrc: "[2001:db8::1]:56324", wantDst: "[2001:db8::2]:443"},
+		{name: "v1Unknown", input: []byte("PROXY UNKNOWN\r\n"), wantSrc: "invalid AddrPort", wantDst: "invalid AddrPort"},
+		{name: "v1UnknownWithAddrs", input: []byte("PROXY UNKNOWN ffff:f...f:ffff ffff:f...f:ffff 65535 65535\r\n"), wantSrc: "invalid AddrPort", wantDst: "invalid AddrPort"},
+		{name: "v1FamilyMismatch", input: []byte("PROXY TCP4 2001:db8::1 198.51.100.1 56324 443\r\n"), wantErr: true},
+		{name: "v1BadPort", input: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 65536 443\r\n"), wantErr: true},
+		{name: "v1LeadingZeroPort", input: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 080 443\r\n"), wantErr: true},
+		{name: "v1TooFewFields", input: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324\r\n"), wantErr: true},
+		{name: "v1DoubleSpace", input: []byte("PROXY TCP4  192.0.2.1 198.51.100.1 56324 443\r\n"), wantErr: true},
+		{name: "v1UDP", input: []byte("PROXY UDP4 192.0.2.1 198.51.100.1 56324 443\r\n"), wantErr: true},
+		{name: "v1NoCRLF", input: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443"), wantErr: true},
+		{name: "v1TooLong", input: []byte("PROXY UNKNOWN " + strings.Repeat("x", 100) + "\r\n"), wantErr: true},
+		{name: "noSignature", input: []byte("GET / HTTP/1.1\r\n\r\n"), wantErr: true},
+		{name: "short", input: []byte("PROXY"), wantErr: true},
+		{name: "v2TCP4", input: proxyV2Header(0x1, 0x11, proxyV2Addrs(src4, dst4)), wantSrc: "192.0.2.1:56324", wantDst: "198.51.100.1:443"},
+		{name: "v2TCP6", input: proxyV2Header(0x1, 0x21, proxyV2Addrs(src6, dst6)), wantSrc: "[2001:db8::1]:56324", wantDst: "[2001:db8::2]:443"},
+		{name: "v2TLVs", input: proxyV2Header(0x1, 0x11, append(proxyV2Addrs(src4, dst4), 0x04, 0x00, 0x01, 0xff)), wantSrc: "192.0.2.1:56324", wantDst: "198.51.100.1:443"},
+		{name: "v2Local", input: proxyV2Header(0x0, 0x00, nil), wantSrc: "invalid AddrPort", wantDst: "invalid AddrPort"},
+		{name: "v2Unspec", input: proxyV2Header(0x1, 0x00, nil), wantSrc: "invalid AddrPort", wantDst: "invalid AddrPort"},
+		{name: "v2Unix", input: proxyV2Header(0x1, 0x31, make([]byte, 216)), wantSrc: "invalid AddrPort", wantDst: "invalid AddrPort"},
+		{name: "v2Mapped", input: proxyV2Header(0x1, 0x21, proxyV2Addrs(netip.MustParseAddrPort("[::ffff:192.0.2.1]:56324"), dst6)), wantSrc: "192.0.2.1:56324", wantDst: "[2001:db8::2]:443"},
+		{name: "v2UDP4", input: proxyV2Header(0x1, 0x12, proxyV2Addrs(src4, dst4)), wantErr: true},
+		{name: "v2UDP6", input: proxyV2Header(0x1, 0x22, proxyV2Addrs(src6, dst6)), wantErr: true},
+		{name: "v2UnspecTransport", input: proxyV2Header(0x1, 0x10, proxyV2Addrs(src4, dst4)), wantErr: true},
+		{name: "v2ShortAddrs", input: proxyV2Header(0x1, 0x21, proxyV2Addrs(src4, dst4)), wantErr: true},
+		{name: "v2BadCommand", input: proxyV2Header(0x2, 0x11, proxyV2Addrs(src4, dst4)), wantErr: true},
+		{name: "v2BadVersion", input: append(append(append([]byte(nil), proxyV2Signature...), 0x11, 0x11, 0x00, 0x0c), proxyV2Addrs(src4, dst4)...), wantErr: true},
+		{name: "v2Truncated", input: proxyV2Header(0x1, 0x11, proxyV2Addrs(src4, dst4))[:20], wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			const rest = "payload"
+			r := bytes.NewReader(append(tc.input, rest...))
+			h, err := readProxyHeader(r)
+			if tc.wantErr {
+				if !errors.Is(err, ErrInvalidProxyHeader) {
+					t.Errorf("error mismatch, got=%v, want=%v", err, ErrInvalidProxyHeader)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want no error, got=%s", err)
+			}
+			if got, want := h.src.String(), tc.wantSrc; got != want {
+				t.Errorf("source mismatch, got=%s, want=%s", got, want)
+			}
+			if got, want := h.dst.String(), tc.wantDst; got != want {
+				t.Errorf("destination mismatch, got=%s, want=%s", got, want)
+			}
+			if got, _ := io.ReadAll(r); string(got) != rest {
+				t.Errorf("rest mismatch, got=%q, want=%q", got, rest)
+			}
+		})
+	}
+}
+
+func TestReadProxyHeader_versionBeforePayload(t *testing.T) {
+	// The version must be checked before the payload of the length in
+	// the header is read.
+	input := append(append([]byte(nil), proxyV2Signature...), 0x11, 0x11, 0xff, 0xff)
+	_, err := readProxyHeader(bytes.NewReader(input))
+	if got, want := fmt.Sprint(err), "netacl: invalid PROXY protocol header: unsupported version 1"; got != want {
+		t.Errorf("error mismatch, got=%s, want=%s", got, want)
+	}
+}
+
+func FuzzReadProxyHeader(f *testing.F) {
+	f.Add([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"))
+	f.Add([]byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"))
+	f.Add(proxyV2Header(0x1, 0x21, make([]byte, 36)))
+	f.Fuzz(func(t *testing.T, input []byte) {
+		h, err := readProxyHeader(bytes.NewReader(input))
+		if err != nil {
+			if !errors.Is(err, ErrInvalidProxyHeader) {
+				t.Errorf("error mismatch, got=%v", err)
+			}
+			return
+		}
+		if h.src.IsValid() != h.dst.IsValid() {
+			t.Errorf("validity mismatch, src=%s, dst=%s", h.src, h.dst)
+		}
+	})
+}
+
+// acceptOne returns the first connection accepted by l, or an error.
+func acceptOne(t *testing.T, l net.Listener) <-chan net.Conn {
+	t.Helper()
+	ch := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			close(ch)
+			return
+		}
+		ch <- conn
+	}()
+	return ch
+}
+
+func dialAndWrite(t *testing.T, addr net.Addr, data string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial(addr.Network(), addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, err := io.WriteString(conn, data); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func waitClosed(t *testing.T, conn net.Conn) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadAll(conn); err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			t.Fatal("connection is not closed")
+		}
+	}
+}
+
+func TestProxyListener(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var denied []string
+	var errs []error
+	l := NewProxyListener(inner, newTestMatcher(t, "deny 203.0.113.0/24\nallow all"), ProxyOptions{
+		Trusted:       newTestTrusted(t, "allow 127.0.0.0/8"),
+		HeaderTimeout: 500 * time.Millisecond,
+		Options: Options{OnDeny: func(conn net.Conn) {
+			mu.Lock()
+			defer mu.Unlock()
+			denied = append(denied, conn.RemoteAddr().String())
+		}},
+		OnError: func(conn net.Conn, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		},
+	})
+	defer l.Close()
+
+	t.Run("allowed", func(t *testing.T) {
+		ch := acceptOne(t, l)
+		dialAndWrite(t, l.Addr(), "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nhello")
+		conn := <-ch
+		if conn == nil {
+			t.Fatal("accept failed")
+		}
+		defer conn.Close()
+		if got, want := conn.RemoteAddr().String(), "192.0.2.1:56324"; got != want {
+			t.Errorf("remote address mismatch, got=%s, want=%s", got, want)
+		}
+		if got, want := conn.LocalAddr().String(), "198.51.100.1:443"; got != want {
+			t.Errorf("local address mismatch, got=%s, want=%s", got, want)
+		}
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(buf), "hello"; got != want {
+			t.Errorf("data mismatch, got=%s, want=%s", got, want)
+		}
+	})
+	t.Run("deniedThenAllowed", func(t *testing.T) {
+		ch := acceptOne(t, l)
+		deniedConn := dialAndWrite(t, l.Addr(), string(proxyV2Header(0x1, 0x21, proxyV2Addrs(
+			netip.MustParseAddrPort("[::ffff:203.0.113.1]:1234"), netip.MustParseAddrPort("[2001:db8::2]:443")))))
+		waitClosed(t, deniedConn)
+		dialAndWrite(t, l.Addr(), "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n")
+		conn := <-ch
+		if conn == nil {
+			t.Fatal("accept failed")
+		}
+		defer conn.Close()
+		if got, want := conn.RemoteAddr().String(), "[2001:db8::1]:56324"; got != want {
+			t.Errorf("remote address mismatch, got=%s, want=%s", got, want)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if got, want := strings.Join(denied, ","), "203.0.113.1:1234"; got != want {
+			t.Errorf("denied mismatch, got=%s, want=%s", got, want)
+		}
+	})
+	t.Run("invalidHeaderAndTimeout", func(t *testing.T) {
+		ch := acceptOne(t, l)
+		invalidConn := dialAndWrite(t, l.Addr(), "GET / HTTP/1.1\r\n\r\n")
+		waitClosed(t, invalidConn)
+		silentConn := dialAndWrite(t, l.Addr(), "")
+		waitClosed(t, silentConn)
+		dialAndWrite(t, l.Addr(), "PROXY UNKNOWN\r\n")
+		conn := <-ch
+		if conn == nil {
+			t.Fatal("accept failed")
+		}
+		defer conn.Close()
+		if got, want := conn.RemoteAddr().String(), conn.(*net.TCPConn).RemoteAddr().String(); got != want {
+			t.Errorf("remote address mismatch, got=%s, want=%s", got, want)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if got, want := len(errs), 2; got != want {
+			t.Fatalf("error count mismatch, got=%d, want=%d", got, want)
+		}
+		for _, err := range errs {
+			if !errors.Is(err, ErrInvalidProxyHeader) {
+				t.Errorf("error mismatch, got=%v, want=%v", err, ErrInvalidProxyHeader)
+			}
+		}
+	})
+}
+
+func TestProxyListener_untrusted(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewProxyListener(inner, newTestMatcher(t, "allow 127.0.0.0/8\ndeny all"), ProxyOptions{
+		Trusted: newTestTrusted(t, "deny 127.0.0.1\nallow 127.0.0.0/8"),
+	})
+	defer l.Close()
+
+	// The header from an untrusted peer is passed through as data and
+	// the access control list is applied to the peer address.
+	const header = "PROXY TCP4 203.0.113.1 198.51.100.1 56324 443\r\n"
+	ch := acceptOne(t, l)
+	dialAndWrite(t, l.Addr(), header)
+	conn := <-ch
+	if conn == nil {
+		t.Fatal("accept failed")
+	}
+	defer conn.Close()
+	if got, want := conn.RemoteAddr().(*net.TCPAddr).IP.String(), "127.0.0.1"; got != want {
+		t.Errorf("remote address mismatch, got=%s, want=%s", got, want)
+	}
+	buf := make([]byte, len(header))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf), header; got != want {
+		t.Errorf("data mismatch, got=%q, want=%q", got, want)
+	}
+}
+
+func TestProxyListener_slowUpstream(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewProxyListener(inner, newTestMatcher(t, "allow all"), ProxyOptions{
+		Trusted:       newTestTrusted(t, "allow 127.0.0.0/8"),
+		HeaderTimeout: time.Minute,
+	})
+	defer l.Close()
+
+	// A trusted upstream which does not send a header must not block
+	// the connections after it.
+	ch := acceptOne(t, l)
+	dialAndWrite(t, l.Addr(), "")
+	dialAndWrite(t, l.Addr(), "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n")
+	select {
+	case conn := <-ch:
+		if conn == nil {
+			t.Fatal("accept failed")
+		}
+		defer conn.Close()
+		if got, want := conn.RemoteAddr().String(), "192.0.2.1:56324"; got != want {
+			t.Errorf("remote address mismatch, got=%s, want=%s", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("accept is blocked by the slow upstream")
+	}
+}
+
+func TestProxyListener_close(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewProxyListener(inner, newTestMatcher(t, "allow all"), ProxyOptions{})
+	ch := make(chan error, 1)
+	go func() {
+		_, err := l.Accept()
+		ch <- err
+	}()
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-ch; !errors.Is(err, net.ErrClosed) {
+		t.Errorf("error mismatch, got=%v, want=%v", err, net.ErrClosed)
+	}
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("error mismatch after close, got=%v, want=%v", err, net.ErrClosed)
+	}
+}
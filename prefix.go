@@ -0,0 +1,33 @@
+package ipacl
+
+import "net/netip"
+
+// AggregatePrefixes returns the minimal list of prefixes which cover the same
+// addresses as prefixes. The prefixes may be of both address families.
+// The returned prefixes are masked, do not overlap and are sorted in
+// increasing order with IPv4 prefixes first. Invalid prefixes are ignored.
+func AggregatePrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	return ExcludePrefixes(prefixes, nil)
+}
+
+// ExcludePrefixes returns the minimal list of prefixes which cover the
+// addresses in prefixes but not in exclude. The returned prefixes are in the
+// same form as AggregatePrefixes returns. Invalid prefixes are ignored.
+func ExcludePrefixes(prefixes, exclude []netip.Prefix) []netip.Prefix {
+	var result []netip.Prefix
+	for _, r := range SubtractRanges(prefixRanges(prefixes), prefixRanges(exclude)) {
+		result = append(result, r.Prefixes()...)
+	}
+	return result
+}
+
+// prefixRanges returns the ranges of the valid prefixes in prefixes.
+func prefixRanges(prefixes []netip.Prefix) []Range {
+	ranges := make([]Range, 0, len(prefixes))
+	for _, p := range prefixes {
+		if p.IsValid() {
+			ranges = append(ranges, RangeFromPrefix(p))
+		}
+	}
+	return ranges
+}
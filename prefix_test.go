@@ -0,0 +1,145 @@
+package ipacl
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+func TestAggregatePrefixes(t *testing.T) {
+	testCases := []struct {
+		input []string
+		want  string
+	}{
+		{input: nil, want: "[]"},
+		{
+			input: []string{"192.0.2.0/25", "192.0.2.128/25", "2001:db8::/33", "2001:db8:8000::/33", "10.0.0.1/8"},
+			want:  "[10.0.0.0/8 192.0.2.0/24 2001:db8::/32]",
+		},
+		{
+			input: []string{"192.0.2.0/24", "192.0.2.1/32", "198.51.100.0/24", "198.51.101.0/24"},
+			want:  "[192.0.2.0/24 198.51.100.0/23]",
+		},
+		{
+			input: []string{"192.0.2.1/32", "192.0.2.2/32"},
+			want:  "[192.0.2.1/32 192.0.2.2/32]",
+		},
+		{
+			input: []string{"2001:db8::/32", "0.0.0.0/0", "::/0"},
+			want:  "[0.0.0.0/0 ::/0]",
+		},
+	}
+	for _, tc := range testCases {
+		if got := fmt.Sprint(AggregatePrefixes(mustParsePrefixes(tc.input))); got != tc.want {
+			t.Errorf("result mismatch, input=%v, got=%s, want=%s", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestExcludePrefixes(t *testing.T) {
+	testCases := []struct {
+		input, exclude []string
+		want           string
+	}{
+		{
+			input:   []string{"192.0.2.0/24"},
+			exclude: []string{"192.0.2.0/26"},
+			want:    "[192.0.2.64/26 192.0.2.128/25]",
+		},
+		{
+			input:   []string{"10.0.0.0/8", "2001:db8::/32"},
+			exclude: []string{"10.0.0.0/9", "10.255.255.255/32", "2001:db8::/33"},
+			want:    "[10.128.0.0/10 10.192.0.0/11 10.224.0.0/12 10.240.0.0/13 10.248.0.0/14 10.252.0.0/15 10.254.0.0/16 10.255.0.0/17 10.255.128.0/18 10.255.192.0/19 10.255.224.0/20 10.255.240.0/21 10.255.248.0/22 10.255.252.0/23 10.255.254.0/24 10.255.255.0/25 10.255.255.128/26 10.255.255.192/27 10.255.255.224/28 10.255.255.240/29 10.255.255.248/30 10.255.255.252/31 10.255.255.254/32 2001:db8:8000::/33]",
+		},
+		{
+			input:   []string{"192.0.2.0/24"},
+			exclude: []string{"0.0.0.0/0"},
+			want:    "[]",
+		},
+		{
+			input:   []string{"192.0.2.0/24"},
+			exclude: []string{"::/0"},
+			want:    "[192.0.2.0/24]",
+		},
+	}
+	for _, tc := range testCases {
+		got := fmt.Sprint(ExcludePrefixes(mustParsePrefixes(tc.input), mustParsePrefixes(tc.exclude)))
+		if got != tc.want {
+			t.Errorf("result mismatch, input=%v, exclude=%v, got=%s, want=%s", tc.input, tc.exclude, got, tc.want)
+		}
+	}
+}
+
+func TestExcludePrefixes_random(t *testing.T) {
+	bases := []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("2001:db8::/120"),
+	}
+	rnd := rand.New(rand.NewSource(1))
+	randomPrefixes := func() []netip.Prefix {
+		prefixes := make([]netip.Prefix, rnd.Intn(8))
+		for i := range prefixes {
+			base := bases[rnd.Intn(len(bases))]
+			a := base.Addr().As16()
+			if base.Addr().Is4() {
+				a4 := base.Addr().As4()
+				a4[3] = byte(rnd.Intn(256))
+				prefixes[i] = netip.PrefixFrom(netip.AddrFrom4(a4), 24+rnd.Intn(9))
+			} else {
+				a[15] = byte(rnd.Intn(256))
+				prefixes[i] = netip.PrefixFrom(netip.AddrFrom16(a), 120+rnd.Intn(9))
+			}
+		}
+		return prefixes
+	}
+	contains := func(prefixes []netip.Prefix, addr netip.Addr) bool {
+		for _, p := range prefixes {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := 0; i < 1000; i++ {
+		input, exclude := randomPrefixes(), randomPrefixes()
+		aggregated := AggregatePrefixes(input)
+		excluded := ExcludePrefixes(input, exclude)
+
+		for _, base := range bases {
+			for addr := base.Addr(); base.Contains(addr); addr = addr.Next() {
+				if got, want := contains(aggregated, addr), contains(input, addr); got != want {
+					t.Fatalf("aggregate mismatch, input=%v, addr=%s, got=%v, want=%v", input, addr, got, want)
+				}
+				if got, want := contains(excluded, addr), contains(input, addr) && !contains(exclude, addr); got != want {
+					t.Fatalf("exclude mismatch, input=%v, exclude=%v, addr=%s, got=%v, want=%v", input, exclude, addr, got, want)
+				}
+			}
+		}
+
+		// The result is minimal if it equals the CIDR decomposition of the
+		// merged ranges.
+		for _, result := range [][]netip.Prefix{aggregated, excluded} {
+			var ranges []Range
+			for _, p := range result {
+				ranges = append(ranges, RangeFromPrefix(p))
+			}
+			var want []netip.Prefix
+			for _, r := range MergeRanges(ranges) {
+				want = append(want, r.Prefixes()...)
+			}
+			if got, want := fmt.Sprint(result), fmt.Sprint(want); got != want {
+				t.Fatalf("result not minimal, input=%v, exclude=%v, got=%s, want=%s", input, exclude, got, want)
+			}
+		}
+	}
+}
+
+func mustParsePrefixes(ss []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, len(ss))
+	for i, s := range ss {
+		prefixes[i] = netip.MustParsePrefix(s)
+	}
+	return prefixes
+}
@@ -0,0 +1,217 @@
+package ipacl
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// Range is a range of IP addresses from the start address to the end address
+// inclusive. The start and end addresses are in the same address family.
+type Range struct {
+	start netip.Addr
+	end   netip.Addr
+}
+
+// RangeFrom returns a range from start to end inclusive.
+// If start and end are not in the same address family or start is after end,
+// the returned range is invalid.
+func RangeFrom(start, end netip.Addr) Range {
+	r := Range{start: start, end: end}
+	if !r.IsValid() {
+		return Range{}
+	}
+	return r
+}
+
+// RangeFromPrefix returns the range of the addresses in p.
+// If p is invalid, the returned range is invalid.
+func RangeFromPrefix(p netip.Prefix) Range {
+	if !p.IsValid() {
+		return Range{}
+	}
+	if p.Addr().Is4() {
+		return v4RangeFromPrefix(p).NetIPRange()
+	}
+	return v6RangeFromPrefix(p).NetIPRange()
+}
+
+// ParseRange parses s as a range in the form of "start-end" or "start",
+// which is the same form as Range.String returns.
+func ParseRange(s string) (Range, error) {
+	before, after, found := strings.Cut(s, "-")
+	start, err := netip.ParseAddr(before)
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid range %q: %w", s, err)
+	}
+	end := start
+	if found {
+		end, err = netip.ParseAddr(after)
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid range %q: %w", s, err)
+		}
+	}
+	r := RangeFrom(start, end)
+	if !r.IsValid() {
+		return Range{}, fmt.Errorf("invalid range %q", s)
+	}
+	return r, nil
+}
+
+// MustParseRange calls ParseRange(s) and panics on error.
+// It is intended for use in tests with hard-coded strings.
+func MustParseRange(s string) Range {
+	r, err := ParseRange(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Start returns the start address of the range.
+func (r Range) Start() netip.Addr {
+	return r.start
+}
+
+// End returns the end address of the range.
+func (r Range) End() netip.Addr {
+	return r.end
+}
+
+// IsValid reports whether the range is valid.
+func (r Range) IsValid() bool {
+	return r.start.IsValid() && r.end.IsValid() &&
+		r.start.Is4() == r.end.Is4() &&
+		r.start.Zone() == "" && r.end.Zone() == "" &&
+		r.start.Compare(r.end) <= 0
+}
+
+// String returns the string representation of the range in the form of
+// "start-end", or "start" if the range has only one address.
+func (r Range) String() string {
+	if !r.IsValid() {
+		return "invalid Range"
+	}
+	var b strings.Builder
+	b.WriteString(r.start.String())
+	if r.end != r.start {
+		b.WriteByte('-')
+		b.WriteString(r.end.String())
+	}
+	return b.String()
+}
+
+// Contains reports whether the range contains addr.
+// A zoned address is never contained.
+func (r Range) Contains(addr netip.Addr) bool {
+	return r.IsValid() && addr.IsValid() && addr.Zone() == "" &&
+		addr.Is4() == r.start.Is4() &&
+		r.start.Compare(addr) <= 0 && addr.Compare(r.end) <= 0
+}
+
+// ContainsRange reports whether the range contains all addresses in o.
+func (r Range) ContainsRange(o Range) bool {
+	return r.IsValid() && o.IsValid() && r.start.Is4() == o.start.Is4() &&
+		r.start.Compare(o.start) <= 0 && o.end.Compare(r.end) <= 0
+}
+
+// Overlaps reports whether the range and o have at least one address in common.
+func (r Range) Overlaps(o Range) bool {
+	return r.IsValid() && o.IsValid() && r.start.Is4() == o.start.Is4() &&
+		r.start.Compare(o.end) <= 0 && o.start.Compare(r.end) <= 0
+}
+
+// IsNeighbor reports whether o starts just after the range ends, or o ends
+// just before the range starts.
+func (r Range) IsNeighbor(o Range) bool {
+	return r.IsValid() && o.IsValid() &&
+		(r.end.Next() == o.start || o.end.Next() == r.start)
+}
+
+// Prefixes returns the minimal list of prefixes which cover the range
+// exactly, in increasing order. It returns nil if the range is invalid.
+func (r Range) Prefixes() []netip.Prefix {
+	if !r.IsValid() {
+		return nil
+	}
+	if r.start.Is4() {
+		return v4Range{start: v4AddrFromBytes(r.start.As4()), end: v4AddrFromBytes(r.end.As4())}.AppendPrefixes(nil)
+	}
+	return v6Range{start: v6AddrFromBytes(r.start.As16()), end: v6AddrFromBytes(r.end.As16())}.AppendPrefixes(nil)
+}
+
+// Size returns the number of addresses in the range.
+// It returns zero if the range is invalid.
+func (r Range) Size() *big.Int {
+	if !r.IsValid() {
+		return new(big.Int)
+	}
+	if r.start.Is4() {
+		return v4Range{start: v4AddrFromBytes(r.start.As4()), end: v4AddrFromBytes(r.end.As4())}.Size()
+	}
+	return v6Range{start: v6AddrFromBytes(r.start.As16()), end: v6AddrFromBytes(r.end.As16())}.Size()
+}
+
+// MergeRanges returns the minimal list of ranges which cover the same
+// addresses as ranges. The returned ranges are sorted in increasing order
+// with IPv4 ranges first, and neither overlap nor are neighbors of each other.
+// Invalid ranges are ignored.
+func MergeRanges(ranges []Range) []Range {
+	sorted := make([]Range, 0, len(ranges))
+	for _, r := range ranges {
+		if r.IsValid() {
+			sorted = append(sorted, r)
+		}
+	}
+	slices.SortFunc(sorted, func(a, b Range) int {
+		return a.start.Compare(b.start)
+	})
+
+	var merged []Range
+	for _, r := range sorted {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if last.Overlaps(r) || last.end.Next() == r.start {
+				if r.end.Compare(last.end) > 0 {
+					last.end = r.end
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// SubtractRanges returns the minimal list of ranges which cover the addresses
+// in ranges but not in remove. The returned ranges are in the same form as
+// MergeRanges returns. Invalid ranges are ignored.
+func SubtractRanges(ranges, remove []Range) []Range {
+	ranges = MergeRanges(ranges)
+	remove = MergeRanges(remove)
+
+	var result []Range
+	j := 0
+	for _, r := range ranges {
+		for j < len(remove) && remove[j].end.Compare(r.start) < 0 {
+			j++
+		}
+		start := r.start
+		for k := j; k < len(remove) && remove[k].start.Compare(r.end) <= 0; k++ {
+			if remove[k].start.Compare(start) > 0 {
+				result = append(result, Range{start: start, end: remove[k].start.Prev()})
+			}
+			if remove[k].end.Compare(r.end) >= 0 {
+				start = netip.Addr{}
+				break
+			}
+			start = remove[k].end.Next()
+		}
+		if start.IsValid() {
+			result = append(result, Range{start: start, end: r.end})
+		}
+	}
+	return result
+}
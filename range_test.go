@@ -0,0 +1,272 @@
+package ipacl
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+func TestRangeFrom(t *testing.T) {
+	testCases := []struct {
+		start, end string
+		want       string
+	}{
+		{start: "192.0.2.0", end: "192.0.2.255", want: "192.0.2.0-192.0.2.255"},
+		{start: "192.0.2.1", end: "192.0.2.1", want: "192.0.2.1"},
+		{start: "2001:db8::", end: "2001:db8::ff", want: "2001:db8::-2001:db8::ff"},
+		{start: "192.0.2.1", end: "192.0.2.0", want: "invalid Range"},
+		{start: "192.0.2.0", end: "2001:db8::", want: "invalid Range"},
+		{start: "fe80::1%eth0", end: "fe80::2", want: "invalid Range"},
+	}
+	for _, tc := range testCases {
+		got := RangeFrom(netip.MustParseAddr(tc.start), netip.MustParseAddr(tc.end)).String()
+		if got != tc.want {
+			t.Errorf("result mismatch, start=%s, end=%s, got=%s, want=%s", tc.start, tc.end, got, tc.want)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	testCases := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "192.0.2.0-192.0.2.255", want: "192.0.2.0-192.0.2.255"},
+		{input: "192.0.2.1", want: "192.0.2.1"},
+		{input: "192.0.2.1-192.0.2.1", want: "192.0.2.1"},
+		{input: "2001:db8::-2001:db8::ff", want: "2001:db8::-2001:db8::ff"},
+		{input: "192.0.2.1-192.0.2.0", wantErr: true},
+		{input: "192.0.2.0-2001:db8::", wantErr: true},
+		{input: "fe80::1%eth0-fe80::2", wantErr: true},
+		{input: "192.0.2.0/24", wantErr: true},
+		{input: "192.0.2.0-", wantErr: true},
+		{input: "", wantErr: true},
+	}
+	for _, tc := range testCases {
+		r, err := ParseRange(tc.input)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("want error, input=%s, got=%s", tc.input, r)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("want no error, input=%s, got=%s", tc.input, err)
+			continue
+		}
+		if got := r.String(); got != tc.want {
+			t.Errorf("result mismatch, input=%s, got=%s, want=%s", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestRangeFromPrefix(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{input: "192.0.2.1/24", want: "192.0.2.0-192.0.2.255"},
+		{input: "192.0.2.1/32", want: "192.0.2.1"},
+		{input: "2001:db8::/32", want: "2001:db8::-2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"},
+	}
+	for _, tc := range testCases {
+		if got := RangeFromPrefix(netip.MustParsePrefix(tc.input)).String(); got != tc.want {
+			t.Errorf("result mismatch, input=%s, got=%s, want=%s", tc.input, got, tc.want)
+		}
+	}
+	if got := RangeFromPrefix(netip.Prefix{}); got.IsValid() {
+		t.Errorf("want invalid range for invalid prefix, got=%s", got)
+	}
+}
+
+func TestRange_Contains(t *testing.T) {
+	r := MustParseRange("192.0.2.10-192.0.2.20")
+	testCases := []struct {
+		input string
+		want  bool
+	}{
+		{input: "192.0.2.9", want: false},
+		{input: "192.0.2.10", want: true},
+		{input: "192.0.2.20", want: true},
+		{input: "192.0.2.21", want: false},
+		{input: "::ffff:192.0.2.10", want: false},
+	}
+	for _, tc := range testCases {
+		if got := r.Contains(netip.MustParseAddr(tc.input)); got != tc.want {
+			t.Errorf("result mismatch, input=%s, got=%v, want=%v", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestRange_relations(t *testing.T) {
+	testCases := []struct {
+		a, b          string
+		overlaps      bool
+		isNeighbor    bool
+		containsRange bool
+	}{
+		{a: "192.0.2.0-192.0.2.255", b: "192.0.2.10-192.0.2.20", overlaps: true, containsRange: true},
+		{a: "192.0.2.10-192.0.2.20", b: "192.0.2.0-192.0.2.255", overlaps: true},
+		{a: "192.0.2.0-192.0.2.10", b: "192.0.2.10-192.0.2.20", overlaps: true},
+		{a: "192.0.2.0-192.0.2.9", b: "192.0.2.10-192.0.2.20", isNeighbor: true},
+		{a: "192.0.2.10-192.0.2.20", b: "192.0.2.0-192.0.2.9", isNeighbor: true},
+		{a: "192.0.2.0-192.0.2.8", b: "192.0.2.10-192.0.2.20"},
+		{a: "255.255.255.255", b: "::"},
+		{a: "0.0.0.0-255.255.255.255", b: "::-::ffff"},
+	}
+	for _, tc := range testCases {
+		a, b := MustParseRange(tc.a), MustParseRange(tc.b)
+		if got := a.Overlaps(b); got != tc.overlaps {
+			t.Errorf("Overlaps mismatch, a=%s, b=%s, got=%v, want=%v", a, b, got, tc.overlaps)
+		}
+		if got := a.IsNeighbor(b); got != tc.isNeighbor {
+			t.Errorf("IsNeighbor mismatch, a=%s, b=%s, got=%v, want=%v", a, b, got, tc.isNeighbor)
+		}
+		if got := a.ContainsRange(b); got != tc.containsRange {
+			t.Errorf("ContainsRange mismatch, a=%s, b=%s, got=%v, want=%v", a, b, got, tc.containsRange)
+		}
+	}
+}
+
+func TestRange_Prefixes(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{input: "192.0.2.0-192.0.2.255", want: "[192.0.2.0/24]"},
+		{input: "192.0.2.1-192.0.2.6", want: "[192.0.2.1/32 192.0.2.2/31 192.0.2.4/31 192.0.2.6/32]"},
+		{input: "0.0.0.0-255.255.255.255", want: "[0.0.0.0/0]"},
+		{input: "2001:db8::-2001:db8::1:0", want: "[2001:db8::/112 2001:db8::1:0/128]"},
+	}
+	for _, tc := range testCases {
+		if got := fmt.Sprint(MustParseRange(tc.input).Prefixes()); got != tc.want {
+			t.Errorf("result mismatch, input=%s, got=%s, want=%s", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestRange_Size(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{input: "192.0.2.0-192.0.2.255", want: "256"},
+		{input: "0.0.0.0-255.255.255.255", want: "4294967296"},
+		{input: "::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", want: "340282366920938463463374607431768211456"},
+	}
+	for _, tc := range testCases {
+		if got := MustParseRange(tc.input).Size().String(); got != tc.want {
+			t.Errorf("result mismatch, input=%s, got=%s, want=%s", tc.input, got, tc.want)
+		}
+	}
+	if got := (Range{}).Size().String(); got != "0" {
+		t.Errorf("result mismatch for invalid range, got=%s, want=0", got)
+	}
+}
+
+func TestMergeRanges(t *testing.T) {
+	testCases := []struct {
+		input []string
+		want  string
+	}{
+		{input: nil, want: "[]"},
+		{
+			input: []string{"2001:db8::-2001:db8::ff", "192.0.2.10-192.0.2.20", "192.0.2.0-192.0.2.9", "192.0.2.15-192.0.2.30", "192.0.2.32"},
+			want:  "[192.0.2.0-192.0.2.30 192.0.2.32 2001:db8::-2001:db8::ff]",
+		},
+		{
+			input: []string{"192.0.2.0-255.255.255.255", "255.255.255.0-255.255.255.255", "::"},
+			want:  "[192.0.2.0-255.255.255.255 ::]",
+		},
+	}
+	for _, tc := range testCases {
+		var ranges []Range
+		for _, s := range tc.input {
+			ranges = append(ranges, MustParseRange(s))
+		}
+		if got := fmt.Sprint(MergeRanges(ranges)); got != tc.want {
+			t.Errorf("result mismatch, input=%v, got=%s, want=%s", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestSubtractRanges(t *testing.T) {
+	testCases := []struct {
+		ranges, remove []string
+		want           string
+	}{
+		{
+			ranges: []string{"192.0.2.0-192.0.2.255"},
+			remove: []string{"192.0.2.10-192.0.2.19", "192.0.2.100"},
+			want:   "[192.0.2.0-192.0.2.9 192.0.2.20-192.0.2.99 192.0.2.101-192.0.2.255]",
+		},
+		{
+			ranges: []string{"192.0.2.0-192.0.2.9", "192.0.2.20-192.0.2.29"},
+			remove: []string{"192.0.2.5-192.0.2.24"},
+			want:   "[192.0.2.0-192.0.2.4 192.0.2.25-192.0.2.29]",
+		},
+		{
+			ranges: []string{"0.0.0.0-255.255.255.255", "::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+			remove: []string{"0.0.0.0", "255.255.255.255", "::-::ffff"},
+			want:   "[0.0.0.1-255.255.255.254 ::1:0-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]",
+		},
+		{
+			ranges: []string{"192.0.2.0-192.0.2.255"},
+			remove: []string{"0.0.0.0-255.255.255.255"},
+			want:   "[]",
+		},
+	}
+	for _, tc := range testCases {
+		var ranges, remove []Range
+		for _, s := range tc.ranges {
+			ranges = append(ranges, MustParseRange(s))
+		}
+		for _, s := range tc.remove {
+			remove = append(remove, MustParseRange(s))
+		}
+		if got := fmt.Sprint(SubtractRanges(ranges, remove)); got != tc.want {
+			t.Errorf("result mismatch, ranges=%v, remove=%v, got=%s, want=%s", tc.ranges, tc.remove, got, tc.want)
+		}
+	}
+}
+
+func TestMergeSubtractRanges_random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomRanges := func() []Range {
+		ranges := make([]Range, rnd.Intn(5))
+		for i := range ranges {
+			a, b := byte(rnd.Intn(256)), byte(rnd.Intn(256))
+			ranges[i] = RangeFrom(netip.AddrFrom4([4]byte{192, 0, 2, min(a, b)}), netip.AddrFrom4([4]byte{192, 0, 2, max(a, b)}))
+		}
+		return ranges
+	}
+	contains := func(ranges []Range, addr netip.Addr) bool {
+		for _, r := range ranges {
+			if r.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < 1000; i++ {
+		ranges, remove := randomRanges(), randomRanges()
+		merged := MergeRanges(ranges)
+		subtracted := SubtractRanges(ranges, remove)
+		for j := 1; j < len(subtracted); j++ {
+			if subtracted[j-1].Overlaps(subtracted[j]) || subtracted[j-1].IsNeighbor(subtracted[j]) {
+				t.Fatalf("ranges not minimal, ranges=%v, remove=%v, got=%v", ranges, remove, subtracted)
+			}
+		}
+		for k := 0; k < 256; k++ {
+			addr := netip.AddrFrom4([4]byte{192, 0, 2, byte(k)})
+			if got, want := contains(merged, addr), contains(ranges, addr); got != want {
+				t.Fatalf("merge mismatch, ranges=%v, addr=%s, got=%v, want=%v", ranges, addr, got, want)
+			}
+			if got, want := contains(subtracted, addr), contains(ranges, addr) && !contains(remove, addr); got != want {
+				t.Fatalf("subtract mismatch, ranges=%v, remove=%v, addr=%s, got=%v, want=%v", ranges, remove, addr, got, want)
+			}
+		}
+	}
+}
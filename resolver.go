@@ -0,0 +1,269 @@
+package ipacl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Resolver is the interface to resolve a hostname to IP addresses.
+// The method signature is same as net.Resolver.LookupNetIP, so
+// *net.Resolver satisfies this interface.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// DefaultResolver is the resolver used when no resolver is specified.
+var DefaultResolver Resolver = net.DefaultResolver
+
+// StaticResolver is a resolver which resolves hostnames with a static map.
+// It is useful for tests.
+type StaticResolver map[string][]netip.Addr
+
+// LookupNetIP returns the addresses of host in the map. Hostnames are
+// compared case-insensitively, as host rules have lowercased hostnames.
+// network must be one of "ip", "ip4" and "ip6".
+func (r StaticResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	var addrs []netip.Addr
+	for _, addr := range r.lookup(host) {
+		switch network {
+		case "ip":
+		case "ip4":
+			if !addr.Is4() {
+				continue
+			}
+		case "ip6":
+			if !addr.Is6() {
+				continue
+			}
+		default:
+			return nil, net.UnknownNetworkError(network)
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+func (r StaticResolver) lookup(host string) []netip.Addr {
+	if addrs, ok := r[host]; ok {
+		return addrs
+	}
+	for h, addrs := range r {
+		if strings.EqualFold(h, host) {
+			return addrs
+		}
+	}
+	return nil
+}
+
+// ResolveFailurePolicy is the policy for a host rule whose hostname cannot be resolved.
+type ResolveFailurePolicy int
+
+const (
+	// KeepLastGood uses the addresses of the last successful resolution.
+	// If the hostname has never been resolved, it behaves as FailClosed.
+	KeepLastGood ResolveFailurePolicy = iota
+	// FailClosed makes an allow rule match no address and a deny rule
+	// match all addresses.
+	FailClosed
+	// DropRule makes the rule match no address.
+	DropRule
+)
+
+// String returns the string representation of the policy.
+func (p ResolveFailurePolicy) String() string {
+	switch p {
+	case KeepLastGood:
+		return "keep-last-good"
+	case FailClosed:
+		return "fail-closed"
+	case DropRule:
+		return "drop-rule"
+	default:
+		panic("invalid ResolveFailurePolicy")
+	}
+}
+
+// ResolveRules returns rules with each host rule replaced by rules for the
+// resolved addresses, keeping the rule order.
+//
+// The returned rules are always usable. The returned error is not nil if
+// some hostnames failed to be resolved, and those host rules are handled with
+// policy. Since ResolveRules has no history, KeepLastGood behaves as FailClosed.
+func ResolveRules(ctx context.Context, rules []Rule, resolver Resolver, policy ResolveFailurePolicy) ([]Rule, error) {
+	h := hostResolver{resolver: resolver, policy: policy}
+	return h.resolve(ctx, rules)
+}
+
+type hostResolver struct {
+	resolver Resolver
+	policy   ResolveFailurePolicy
+
+	// lastGood is the addresses of the last successful resolution
+	// for each hostname. It is nil if history is not kept.
+	lastGood map[string][]netip.Addr
+}
+
+func (h *hostResolver) resolve(ctx context.Context, rules []Rule) ([]Rule, error) {
+	resolved := make([]Rule, 0, len(rules))
+	var errs []error
+	for _, rule := range rules {
+		if rule.host == "" {
+			resolved = append(resolved, rule)
+			continue
+		}
+
+		addrs, err := lookupHostAddrs(ctx, h.resolver, rule.host)
+		if err == nil {
+			if h.lastGood != nil {
+				h.lastGood[rule.host] = addrs
+			}
+			resolved = appendAddrRules(resolved, addrs, rule.action)
+			continue
+		}
+
+		errs = append(errs, fmt.Errorf("resolve host %q: %w", rule.host, err))
+		policy := h.policy
+		if policy == KeepLastGood {
+			if addrs, ok := h.lastGood[rule.host]; ok {
+				resolved = appendAddrRules(resolved, addrs, rule.action)
+				continue
+			}
+			policy = FailClosed
+		}
+		if policy == FailClosed && rule.action == Deny {
+			resolved = append(resolved, NewRule(allIPv4CIDR, Deny), NewRule(allIPv6CIDR, Deny))
+		}
+	}
+	return resolved, errors.Join(errs...)
+}
+
+func lookupHostAddrs(ctx context.Context, resolver Resolver, host string) ([]netip.Addr, error) {
+	addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]netip.Addr, 0, len(addrs))
+	for _, addr := range addrs {
+		if addr.IsValid() {
+			res = append(res, addr.Unmap().WithZone(""))
+		}
+	}
+	if len(res) == 0 {
+		return nil, errors.New("no addresses")
+	}
+	slices.SortFunc(res, netip.Addr.Compare)
+	return slices.Compact(res), nil
+}
+
+func appendAddrRules(rules []Rule, addrs []netip.Addr, action Action) []Rule {
+	for _, addr := range addrs {
+		rules = append(rules, NewRule(netip.PrefixFrom(addr, addr.BitLen()), action))
+	}
+	return rules
+}
+
+// DefaultResolveInterval is the interval of re-resolution used when
+// ResolvingOptions.Interval is zero.
+const DefaultResolveInterval = time.Minute
+
+// ResolvingOptions is the options for NewResolvingACL.
+type ResolvingOptions struct {
+	// Resolver is used to resolve hostnames.
+	// If nil, DefaultResolver is used.
+	Resolver Resolver
+
+	// FailurePolicy is the policy for a hostname which cannot be resolved.
+	FailurePolicy ResolveFailurePolicy
+
+	// Interval is the interval of re-resolution in Run.
+	// If zero, DefaultResolveInterval is used.
+	Interval time.Duration
+
+	// OnError is called with the resolution error in Run if not nil.
+	OnError func(err error)
+}
+
+// ResolvingACL is an access control list which contains host rules and
+// follows the changes of the addresses of the hostnames.
+type ResolvingACL struct {
+	rules    []Rule
+	interval time.Duration
+	onError  func(err error)
+
+	mu       sync.Mutex
+	resolver hostResolver
+
+	current atomic.Pointer[BinarySearch]
+}
+
+// NewResolvingACL creates a ResolvingACL and resolves the hostnames in rules.
+//
+// The returned ACL is always usable. The returned error is not nil if some
+// hostnames failed to be resolved, and those host rules are handled with
+// opts.FailurePolicy.
+func NewResolvingACL(ctx context.Context, rules []Rule, opts ResolvingOptions) (*ResolvingACL, error) {
+	resolver := opts.Resolver
+	if resolver == nil {
+		resolver = DefaultResolver
+	}
+	interval := opts.Interval
+	if interval == 0 {
+		interval = DefaultResolveInterval
+	}
+	a := &ResolvingACL{
+		rules:    slices.Clone(rules),
+		interval: interval,
+		onError:  opts.OnError,
+		resolver: hostResolver{
+			resolver: resolver,
+			policy:   opts.FailurePolicy,
+			lastGood: make(map[string][]netip.Addr),
+		},
+	}
+	err := a.Refresh(ctx)
+	return a, err
+}
+
+// Lookup lookups an IP address and returns the action defined in the access control list.
+func (a *ResolvingACL) Lookup(ip netip.Addr) Action {
+	return a.current.Load().Lookup(ip)
+}
+
+// Refresh resolves the hostnames again and replaces the compiled access control list.
+// The returned error is not nil if some hostnames failed to be resolved.
+func (a *ResolvingACL) Refresh(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rules, err := a.resolver.resolve(ctx, a.rules)
+	s := NewBinarySearch(rules)
+	a.current.Store(&s)
+	return err
+}
+
+// Run calls Refresh at the interval until ctx is done.
+func (a *ResolvingACL) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.Refresh(ctx); err != nil && a.onError != nil {
+				a.onError(err)
+			}
+		}
+	}
+}
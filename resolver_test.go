@@ -0,0 +1,176 @@
+package ipacl
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func TestStaticResolver_LookupNetIP(t *testing.T) {
+	r := StaticResolver{
+		"ci.internal.example": {netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1")},
+		"DB.example":          {netip.MustParseAddr("192.0.2.2")},
+	}
+	testCases := []struct {
+		network, host string
+		want          string
+		wantErr       bool
+	}{
+		{network: "ip", host: "ci.internal.example", want: "[192.0.2.1 2001:db8::1]"},
+		{network: "ip4", host: "ci.internal.example", want: "[192.0.2.1]"},
+		{network: "ip6", host: "ci.internal.example", want: "[2001:db8::1]"},
+		{network: "ip", host: "CI.Internal.Example", want: "[192.0.2.1 2001:db8::1]"},
+		{network: "ip", host: "db.example", want: "[192.0.2.2]"},
+		{network: "ip", host: "unknown.example", wantErr: true},
+		{network: "tcp", host: "ci.internal.example", wantErr: true},
+	}
+	for _, tc := range testCases {
+		addrs, err := r.LookupNetIP(context.Background(), tc.network, tc.host)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("got no error, network=%s, host=%s", tc.network, tc.host)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("want no error, network=%s, host=%s, got: %s", tc.network, tc.host, err)
+		} else if got := gocmp.Diff(tc.want, fmtAddrs(addrs)); got != "" {
+			t.Errorf("result mismatch, network=%s, host=%s, (-want +got):\n%s", tc.network, tc.host, got)
+		}
+	}
+}
+
+func fmtAddrs(addrs []netip.Addr) string {
+	s := "["
+	for i, addr := range addrs {
+		if i > 0 {
+			s += " "
+		}
+		s += addr.String()
+	}
+	return s + "]"
+}
+
+func TestResolveRules(t *testing.T) {
+	resolver := StaticResolver{
+		"a.example": {netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("::ffff:192.0.2.1"), netip.MustParseAddr("192.0.2.1")},
+	}
+	testCases := []struct {
+		rules   string
+		policy  ResolveFailurePolicy
+		want    string
+		wantErr bool
+	}{
+		{
+			rules:  "deny 192.0.2.0/24\nallow host:a.example\ndeny all",
+			policy: FailClosed,
+			want:   "deny 192.0.2.0/24, allow 192.0.2.1/32, allow 2001:db8::1/128, deny 0.0.0.0/0, deny ::/0",
+		},
+		{
+			rules:  "allow host:A.Example\ndeny all",
+			policy: FailClosed,
+			want:   "allow 192.0.2.1/32, allow 2001:db8::1/128, deny 0.0.0.0/0, deny ::/0",
+		},
+		{
+			rules:   "allow host:missing.example\ndeny host:missing.example\nallow all",
+			policy:  FailClosed,
+			want:    "deny 0.0.0.0/0, deny ::/0, allow 0.0.0.0/0, allow ::/0",
+			wantErr: true,
+		},
+		{
+			rules:   "allow host:missing.example\ndeny host:missing.example\nallow all",
+			policy:  KeepLastGood,
+			want:    "deny 0.0.0.0/0, deny ::/0, allow 0.0.0.0/0, allow ::/0",
+			wantErr: true,
+		},
+		{
+			rules:   "allow host:missing.example\ndeny host:missing.example\nallow all",
+			policy:  DropRule,
+			want:    "allow 0.0.0.0/0, allow ::/0",
+			wantErr: true,
+		},
+	}
+	for i, tc := range testCases {
+		rules, err := ParseRuleLines(tc.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ResolveRules(context.Background(), rules, resolver, tc.policy)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("error mismatch for test case %d, got=%v, want=%v", i, err, tc.wantErr)
+		}
+		if diff := gocmp.Diff(tc.want, Rules(got).String()); diff != "" {
+			t.Errorf("rules mismatch for test case %d, (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestResolvingACL(t *testing.T) {
+	rules, err := ParseRuleLines("allow host:a.example\ndeny all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := StaticResolver{"a.example": {netip.MustParseAddr("192.0.2.1")}}
+	ctx := context.Background()
+
+	t.Run("keepLastGood", func(t *testing.T) {
+		a, err := NewResolvingACL(ctx, rules, ResolvingOptions{Resolver: resolver, FailurePolicy: KeepLastGood})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := a.Lookup(netip.MustParseAddr("192.0.2.1")), Allow; got != want {
+			t.Errorf("result mismatch, got=%s, want=%s", got, want)
+		}
+		failing := StaticResolver{}
+		a.resolver.resolver = failing
+		if err := a.Refresh(ctx); err == nil {
+			t.Error("got no error for failed resolution")
+		}
+		if got, want := a.Lookup(netip.MustParseAddr("192.0.2.1")), Allow; got != want {
+			t.Errorf("result mismatch after failure, got=%s, want=%s", got, want)
+		}
+	})
+	t.Run("failClosed", func(t *testing.T) {
+		a, err := NewResolvingACL(ctx, rules, ResolvingOptions{Resolver: resolver, FailurePolicy: FailClosed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.resolver.resolver = StaticResolver{}
+		if err := a.Refresh(ctx); err == nil {
+			t.Error("got no error for failed resolution")
+		}
+		if got, want := a.Lookup(netip.MustParseAddr("192.0.2.1")), Deny; got != want {
+			t.Errorf("result mismatch after failure, got=%s, want=%s", got, want)
+		}
+	})
+	t.Run("run", func(t *testing.T) {
+		changing := StaticResolver{"a.example": {netip.MustParseAddr("192.0.2.1")}}
+		a, err := NewResolvingACL(ctx, rules, ResolvingOptions{Resolver: changing, Interval: time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Replace the resolver before running to avoid a data race on the map.
+		a.resolver.resolver = StaticResolver{"a.example": {netip.MustParseAddr("192.0.2.2")}}
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			a.Run(ctx)
+			close(done)
+		}()
+		deadline := time.Now().Add(5 * time.Second)
+		for a.Lookup(netip.MustParseAddr("192.0.2.2")) != Allow {
+			if time.Now().After(deadline) {
+				t.Fatal("address change was not followed")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+		<-done
+		if got, want := a.Lookup(netip.MustParseAddr("192.0.2.1")), Deny; got != want {
+			t.Errorf("result mismatch for old address, got=%s, want=%s", got, want)
+		}
+	})
+}
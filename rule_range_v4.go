@@ -4,6 +4,7 @@ package ipacl
 
 import (
 	"log"
+	"slices"
 	"strings"
 	"unicode"
 )
@@ -176,3 +177,69 @@ func formatRuleRangeV4List(rules []ruleRangeV4) string {
 	}
 	return b.String()
 }
+
+// attributedRangeV4 is a range of addresses decided by a rule.
+type attributedRangeV4 struct {
+	ipRange v4Range
+	// rule is the index of the rule which decides the addresses in ipRange.
+	rule int
+}
+
+// attributeRulesV4 returns the ranges decided by each rule in rules under
+// first-match semantics, sorted in increasing order.
+// Addresses not matched by any rule are not included.
+func attributeRulesV4(rules []Rule) []attributedRangeV4 {
+	var attributed []attributedRangeV4
+	var covered []ruleRangeV4
+	for i, rule := range rules {
+		if !rule.target.Addr().Is4() {
+			continue
+		}
+		r := v4RangeFromPrefix(rule.target)
+		for _, uncovered := range ruleRangeV4ListUncovered(covered, r) {
+			attributed = append(attributed, attributedRangeV4{ipRange: uncovered, rule: i})
+		}
+		covered = ruleRangeV4ListAddRange(covered, ruleRangeV4{ipRange: r, action: Allow})
+	}
+	slices.SortFunc(attributed, func(a, b attributedRangeV4) int {
+		return a.ipRange.start.Compare(b.ipRange.start)
+	})
+	return attributed
+}
+
+// ruleRangeV4ListUncovered returns the parts of r which are not covered by
+// any element in list.
+// Elements in list must be non-overlapping and be sorted in increasing order.
+func ruleRangeV4ListUncovered(list []ruleRangeV4, r v4Range) []v4Range {
+	var uncovered []v4Range
+	for _, s := range list {
+		if s.ipRange.end.Compare(r.start) < 0 {
+			continue
+		}
+		if s.ipRange.start.Compare(r.end) > 0 {
+			break
+		}
+		if s.ipRange.start.Compare(r.start) > 0 {
+			uncovered = append(uncovered, v4Range{start: r.start, end: s.ipRange.start.Prev()})
+		}
+		if s.ipRange.end.Compare(r.end) >= 0 {
+			return uncovered
+		}
+		r.start = s.ipRange.end.Next()
+	}
+	return append(uncovered, r)
+}
+
+// rulesInAttributedRangesV4 returns the indexes of the rules which decide
+// addresses in r, in increasing order.
+func rulesInAttributedRangesV4(attributed []attributedRangeV4, r v4Range) []int {
+	i, _ := binarySearchNoDupFunc(attributed, r.start, func(e attributedRangeV4, t v4Addr) int {
+		return e.ipRange.end.Compare(t)
+	})
+	var rules []int
+	for ; i < len(attributed) && attributed[i].ipRange.start.Compare(r.end) <= 0; i++ {
+		rules = append(rules, attributed[i].rule)
+	}
+	slices.Sort(rules)
+	return slices.Compact(rules)
+}
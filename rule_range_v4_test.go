@@ -68,3 +68,26 @@ func TestRuleRangeV4ListAddRange(t *testing.T) {
 		})
 	})
 }
+
+func TestRuleRangeV4ListUncovered(t *testing.T) {
+	testCases := []struct {
+		list, r, want string
+	}{
+		{list: "", r: "192.0.2.4-192.0.2.7", want: "192.0.2.4-192.0.2.7"},
+		{list: "192.0.2.4-192.0.2.7", r: "192.0.2.4-192.0.2.7", want: ""},
+		{list: "192.0.2.4-192.0.2.7", r: "192.0.2.0-192.0.2.9", want: "192.0.2.0-192.0.2.3, 192.0.2.8-192.0.2.9"},
+		{list: "!192.0.2.4-192.0.2.7, 192.0.2.9", r: "192.0.2.5-192.0.2.10", want: "192.0.2.8, 192.0.2.10"},
+		{list: "0.0.0.0-192.0.2.3, 192.0.2.8-255.255.255.255", r: "0.0.0.0-255.255.255.255", want: "192.0.2.4-192.0.2.7"},
+	}
+	for _, tc := range testCases {
+		list := mustParseRuleRangeV4List(tc.list)
+		r := mustParseRuleRangeV4(tc.r).ipRange
+		var got []ruleRangeV4
+		for _, u := range ruleRangeV4ListUncovered(list, r) {
+			got = append(got, ruleRangeV4{ipRange: u, action: Allow})
+		}
+		if got := formatRuleRangeV4List(got); got != tc.want {
+			t.Errorf("result mismatch, list=%s, r=%s,\n got=%s,\nwant=%s", tc.list, tc.r, got, tc.want)
+		}
+	}
+}
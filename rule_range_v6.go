@@ -4,6 +4,7 @@ package ipacl
 
 import (
 	"log"
+	"slices"
 	"strings"
 	"unicode"
 )
@@ -176,3 +177,69 @@ func formatRuleRangeV6List(rules []ruleRangeV6) string {
 	}
 	return b.String()
 }
+
+// attributedRangeV6 is a range of addresses decided by a rule.
+type attributedRangeV6 struct {
+	ipRange v6Range
+	// rule is the index of the rule which decides the addresses in ipRange.
+	rule int
+}
+
+// attributeRulesV6 returns the ranges decided by each rule in rules under
+// first-match semantics, sorted in increasing order.
+// Addresses not matched by any rule are not included.
+func attributeRulesV6(rules []Rule) []attributedRangeV6 {
+	var attributed []attributedRangeV6
+	var covered []ruleRangeV6
+	for i, rule := range rules {
+		if !rule.target.Addr().Is6() {
+			continue
+		}
+		r := v6RangeFromPrefix(rule.target)
+		for _, uncovered := range ruleRangeV6ListUncovered(covered, r) {
+			attributed = append(attributed, attributedRangeV6{ipRange: uncovered, rule: i})
+		}
+		covered = ruleRangeV6ListAddRange(covered, ruleRangeV6{ipRange: r, action: Allow})
+	}
+	slices.SortFunc(attributed, func(a, b attributedRangeV6) int {
+		return a.ipRange.start.Compare(b.ipRange.start)
+	})
+	return attributed
+}
+
+// ruleRangeV6ListUncovered returns the parts of r which are not covered by
+// any element in list.
+// Elements in list must be non-overlapping and be sorted in increasing order.
+func ruleRangeV6ListUncovered(list []ruleRangeV6, r v6Range) []v6Range {
+	var uncovered []v6Range
+	for _, s := range list {
+		if s.ipRange.end.Compare(r.start) < 0 {
+			continue
+		}
+		if s.ipRange.start.Compare(r.end) > 0 {
+			break
+		}
+		if s.ipRange.start.Compare(r.start) > 0 {
+			uncovered = append(uncovered, v6Range{start: r.start, end: s.ipRange.start.Prev()})
+		}
+		if s.ipRange.end.Compare(r.end) >= 0 {
+			return uncovered
+		}
+		r.start = s.ipRange.end.Next()
+	}
+	return append(uncovered, r)
+}
+
+// rulesInAttributedRangesV6 returns the indexes of the rules which decide
+// addresses in r, in increasing order.
+func rulesInAttributedRangesV6(attributed []attributedRangeV6, r v6Range) []int {
+	i, _ := binarySearchNoDupFunc(attributed, r.start, func(e attributedRangeV6, t v6Addr) int {
+		return e.ipRange.end.Compare(t)
+	})
+	var rules []int
+	for ; i < len(attributed) && attributed[i].ipRange.start.Compare(r.end) <= 0; i++ {
+		rules = append(rules, attributed[i].rule)
+	}
+	slices.Sort(rules)
+	return slices.Compact(rules)
+}
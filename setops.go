@@ -0,0 +1,44 @@
+package ipacl
+
+// Union returns the access control list which allows an address
+// if a or b allows it.
+func Union(a, b *BinarySearch) BinarySearch {
+	return mergeBinarySearch(a, b, func(x, y bool) bool { return x || y })
+}
+
+// Intersect returns the access control list which allows an address
+// if both a and b allow it.
+func Intersect(a, b *BinarySearch) BinarySearch {
+	return mergeBinarySearch(a, b, func(x, y bool) bool { return x && y })
+}
+
+// Subtract returns the access control list which allows an address
+// if a allows it and b does not allow it.
+func Subtract(a, b *BinarySearch) BinarySearch {
+	return mergeBinarySearch(a, b, func(x, y bool) bool { return x && !y })
+}
+
+// Complement returns the access control list which allows an address
+// if a does not allow it.
+func Complement(a *BinarySearch) BinarySearch {
+	return mergeBinarySearch(a, a, func(x, _ bool) bool { return !x })
+}
+
+// mergeBinarySearch merges a and b with a linear merge over the end addresses
+// for both address families. op returns whether an address is allowed in the
+// result from whether it is allowed in a and b.
+func mergeBinarySearch(a, b *BinarySearch, op func(x, y bool) bool) BinarySearch {
+	var s BinarySearch
+	s.v4EndAddrs, s.v4EvenIndexIsDeny = mergeEndAddrsV4(
+		a.v4EndAddrs, a.v4EvenIndexIsDeny, b.v4EndAddrs, b.v4EvenIndexIsDeny, op)
+	s.v6EndAddrs, s.v6EvenIndexIsDeny = mergeEndAddrsV6(
+		a.v6Ends(), a.v6EvenIndexIsDeny, b.v6Ends(), b.v6EvenIndexIsDeny, op)
+	s.compactV6()
+	return s
+}
+
+// isAllowedIndex returns whether addresses of index i in end addresses
+// are allowed.
+func isAllowedIndex(i int, evenIndexIsDeny bool) bool {
+	return !isDenyIndex(i, evenIndexIsDeny)
+}
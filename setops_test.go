@@ -0,0 +1,126 @@
+package ipacl
+
+import (
+	"math/rand"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestSetOperations(t *testing.T) {
+	testCases := []struct {
+		a, b                                                    string
+		wantUnion, wantIntersect, wantSubtract, wantComplementA string
+	}{
+		{
+			a:               "allow 10.0.0.0/8\ndeny all",
+			b:               "deny 10.5.0.0/16\nallow 10.0.0.0/7\ndeny all",
+			wantUnion:       "BinarySearch{v4:[!0.0.0.0-9.255.255.255, 10.0.0.0-11.255.255.255, !12.0.0.0-255.255.255.255], v6:[!::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]}",
+			wantIntersect:   "BinarySearch{v4:[!0.0.0.0-9.255.255.255, 10.0.0.0-10.4.255.255, !10.5.0.0-10.5.255.255, 10.6.0.0-10.255.255.255, !11.0.0.0-255.255.255.255], v6:[!::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]}",
+			wantSubtract:    "BinarySearch{v4:[!0.0.0.0-10.4.255.255, 10.5.0.0-10.5.255.255, !10.6.0.0-255.255.255.255], v6:[!::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]}",
+			wantComplementA: "BinarySearch{v4:[0.0.0.0-9.255.255.255, !10.0.0.0-10.255.255.255, 11.0.0.0-255.255.255.255], v6:[::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]}",
+		},
+		{
+			a:               "",
+			b:               "deny 2001:db8::/32",
+			wantUnion:       "BinarySearch{v4:[0.0.0.0-255.255.255.255], v6:[::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]}",
+			wantIntersect:   "BinarySearch{v4:[0.0.0.0-255.255.255.255], v6:[::-2001:db7:ffff:ffff:ffff:ffff:ffff:ffff, !2001:db8::-2001:db8:ffff:ffff:ffff:ffff:ffff:ffff, 2001:db9::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]}",
+			wantSubtract:    "BinarySearch{v4:[!0.0.0.0-255.255.255.255], v6:[!::-2001:db7:ffff:ffff:ffff:ffff:ffff:ffff, 2001:db8::-2001:db8:ffff:ffff:ffff:ffff:ffff:ffff, !2001:db9::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]}",
+			wantComplementA: "BinarySearch{v4:[!0.0.0.0-255.255.255.255], v6:[!::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]}",
+		},
+	}
+	for i, tc := range testCases {
+		ra, err := ParseRuleLines(tc.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rb, err := ParseRuleLines(tc.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, b := NewBinarySearch(ra), NewBinarySearch(rb)
+		union, intersect, subtract, complement := Union(&a, &b), Intersect(&a, &b), Subtract(&a, &b), Complement(&a)
+		if got, want := union.String(), tc.wantUnion; got != want {
+			t.Errorf("union mismatch for test case %d,\n got=%s\nwant=%s", i, got, want)
+		}
+		if got, want := intersect.String(), tc.wantIntersect; got != want {
+			t.Errorf("intersect mismatch for test case %d,\n got=%s\nwant=%s", i, got, want)
+		}
+		if got, want := subtract.String(), tc.wantSubtract; got != want {
+			t.Errorf("subtract mismatch for test case %d,\n got=%s\nwant=%s", i, got, want)
+		}
+		if got, want := complement.String(), tc.wantComplementA; got != want {
+			t.Errorf("complement mismatch for test case %d,\n got=%s\nwant=%s", i, got, want)
+		}
+	}
+}
+
+func checkSetOperations(t *testing.T, ra, rb []Rule, ip netip.Addr) {
+	t.Helper()
+	a, b := NewBinarySearch(ra), NewBinarySearch(rb)
+	x, y := a.Lookup(ip) == Allow, b.Lookup(ip) == Allow
+	testCases := []struct {
+		name string
+		s    BinarySearch
+		want bool
+	}{
+		{name: "union", s: Union(&a, &b), want: x || y},
+		{name: "intersect", s: Intersect(&a, &b), want: x && y},
+		{name: "subtract", s: Subtract(&a, &b), want: x && !y},
+		{name: "complement", s: Complement(&a), want: !x},
+	}
+	for _, tc := range testCases {
+		if got := tc.s.Lookup(ip) == Allow; got != tc.want {
+			t.Fatalf("%s result mismatch, a=%s, b=%s, ip=%s, got=%v, want=%v", tc.name, Rules(ra), Rules(rb), ip, got, tc.want)
+		}
+	}
+}
+
+func TestSetOperations_Exhaustive(t *testing.T) {
+	bases := []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.0/28"),
+		netip.MustParsePrefix("2001:db8::/124"),
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		var sa, sb strings.Builder
+		for _, base := range bases {
+			sa.WriteString(randomRuleLines(rnd, base, rnd.Intn(6)))
+			sb.WriteString(randomRuleLines(rnd, base, rnd.Intn(6)))
+		}
+		ra, err := ParseRuleLines(sa.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rb, err := ParseRuleLines(sb.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, base := range bases {
+			ip := base.Addr().Prev()
+			for j := 0; j < 1<<(base.Addr().BitLen()-base.Bits())+2; j++ {
+				checkSetOperations(t, ra, rb, ip)
+				ip = ip.Next()
+			}
+		}
+	}
+}
+
+func FuzzSetOperations(f *testing.F) {
+	f.Add("allow 10.0.0.0/8\ndeny all", "deny 10.5.0.0/16\nallow 10.0.0.0/7\ndeny all", "10.5.0.1")
+	f.Fuzz(func(t *testing.T, sa, sb, input string) {
+		ra, err := ParseRuleLines(sa)
+		if err != nil || hasHostRule(ra) {
+			t.Skip()
+		}
+		rb, err := ParseRuleLines(sb)
+		if err != nil || hasHostRule(rb) {
+			t.Skip()
+		}
+		ip, err := netip.ParseAddr(input)
+		if err != nil || strings.Contains(ip.String(), "%") {
+			t.Skip()
+		}
+		checkSetOperations(t, ra, rb, ip)
+	})
+}
@@ -0,0 +1,46 @@
+package ipacl
+
+//go:generate sh -c "./gen_rule_range_v6_go.sh"
+
+// mergeEndAddrsV4 merges two compiled lists of end addresses with the flags
+// whether the even indexes are deny, and returns the end addresses and the
+// flag of the list where an address is allowed if op returns true for whether
+// the address is allowed in the two lists.
+// The returned end addresses always end with the last address.
+func mergeEndAddrsV4(aEnds []v4Addr, aEvenIndexIsDeny bool, bEnds []v4Addr, bEvenIndexIsDeny bool, op func(a, b bool) bool) ([]v4Addr, bool) {
+	var ends []v4Addr
+	evenIndexIsDeny := false
+	lastAllowed := false
+	i, j := 0, 0
+	for {
+		// Addresses after the last end address belong to the next index
+		// in the same way as Lookup.
+		aEnd, bEnd := lastV4Addr, lastV4Addr
+		if i < len(aEnds) {
+			aEnd = aEnds[i]
+		}
+		if j < len(bEnds) {
+			bEnd = bEnds[j]
+		}
+		end := aEnd.Min(bEnd)
+		allowed := op(isAllowedIndex(i, aEvenIndexIsDeny), isAllowedIndex(j, bEvenIndexIsDeny))
+		if n := len(ends); n > 0 && allowed == lastAllowed {
+			ends[n-1] = end
+		} else {
+			if n == 0 {
+				evenIndexIsDeny = !allowed
+			}
+			ends = append(ends, end)
+			lastAllowed = allowed
+		}
+		if end.IsLast() {
+			return ends, evenIndexIsDeny
+		}
+		if aEnd.Compare(end) == 0 {
+			i++
+		}
+		if bEnd.Compare(end) == 0 {
+			j++
+		}
+	}
+}
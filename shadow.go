@@ -0,0 +1,98 @@
+package ipacl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ShadowKind is the kind of shadowing of a rule by earlier rules.
+type ShadowKind int
+
+const (
+	// FullyShadowed means the rule is entirely covered by earlier rules
+	// and does not change the compiled result.
+	FullyShadowed ShadowKind = iota + 1
+	// PartiallyShadowed means the rule is partly covered by earlier rules.
+	PartiallyShadowed
+)
+
+// String returns the string representation of the shadow kind.
+func (k ShadowKind) String() string {
+	switch k {
+	case FullyShadowed:
+		return "fully shadowed"
+	case PartiallyShadowed:
+		return "partially shadowed"
+	default:
+		panic("invalid ShadowKind")
+	}
+}
+
+// Shadow is a rule which is covered by earlier rules.
+type Shadow struct {
+	// Index is the index of the shadowed rule.
+	Index int
+	// Rule is the shadowed rule.
+	Rule Rule
+	// Kind is the kind of shadowing.
+	Kind ShadowKind
+	// ShadowedBy is the indexes of the earlier rules which decide addresses
+	// covered by the rule, in increasing order.
+	ShadowedBy []int
+}
+
+// FindShadowedRules returns the rules which are fully or partially covered
+// by earlier rules under first-match semantics.
+// Unresolved host rules are ignored.
+func FindShadowedRules(rules []Rule) []Shadow {
+	// Addresses covered by a rule are decided by the rule itself or by
+	// earlier rules, so the rules deciding its range other than itself are
+	// exactly the earlier rules shadowing it.
+	attributedV4 := attributeRulesV4(rules)
+	attributedV6 := attributeRulesV6(rules)
+	var shadows []Shadow
+	for i, rule := range rules {
+		if rule.host != "" {
+			continue
+		}
+
+		var deciding []int
+		if rule.target.Addr().Is4() {
+			deciding = rulesInAttributedRangesV4(attributedV4, v4RangeFromPrefix(rule.target))
+		} else {
+			deciding = rulesInAttributedRangesV6(attributedV6, v6RangeFromPrefix(rule.target))
+		}
+		kind := FullyShadowed
+		shadowedBy := deciding
+		if n := len(deciding); n > 0 && deciding[n-1] == i {
+			kind = PartiallyShadowed
+			shadowedBy = deciding[:n-1]
+		}
+		if len(shadowedBy) == 0 {
+			continue
+		}
+		shadows = append(shadows, Shadow{
+			Index:      i,
+			Rule:       rule,
+			Kind:       kind,
+			ShadowedBy: shadowedBy,
+		})
+	}
+	return shadows
+}
+
+// String returns the string representation of the shadow.
+func (s Shadow) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "rule %d (%s) is %s by rule", s.Index, s.Rule, s.Kind)
+	if len(s.ShadowedBy) > 1 {
+		b.WriteByte('s')
+	}
+	for i, j := range s.ShadowedBy {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, " %d", j)
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package ipacl
+
+import (
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func TestFindShadowedRules(t *testing.T) {
+	testCases := []struct {
+		rules string
+		want  []string
+	}{
+		{
+			rules: "allow 10.0.0.0/8\ndeny 10.1.0.0/16\ndeny all",
+			want: []string{
+				"rule 1 (deny 10.1.0.0/16) is fully shadowed by rule 0",
+				"rule 2 (deny 0.0.0.0/0) is partially shadowed by rule 0",
+			},
+		},
+		{
+			rules: "deny 10.1.0.0/16\nallow 10.0.0.0/8\ndeny all",
+			want: []string{
+				"rule 1 (allow 10.0.0.0/8) is partially shadowed by rule 0",
+				"rule 2 (deny 0.0.0.0/0) is partially shadowed by rules 0, 1",
+			},
+		},
+		{
+			rules: "allow 192.0.2.0/25\ndeny 192.0.2.128/25\nallow 192.0.2.0/24\nallow 2001:db8::/32\ndeny 2001:db8:1::/48\ndeny all",
+			want: []string{
+				"rule 2 (allow 192.0.2.0/24) is fully shadowed by rules 0, 1",
+				"rule 4 (deny 2001:db8:1::/48) is fully shadowed by rule 3",
+				"rule 5 (deny 0.0.0.0/0) is partially shadowed by rules 0, 1",
+				"rule 6 (deny ::/0) is partially shadowed by rule 3",
+			},
+		},
+		{
+			rules: "deny all\nallow 192.0.2.1",
+			want: []string{
+				"rule 2 (allow 192.0.2.1/32) is fully shadowed by rule 0",
+			},
+		},
+		{
+			rules: "allow host:a.example\nallow 192.0.2.0/24\nallow 192.0.2.0/24",
+			want: []string{
+				"rule 2 (allow 192.0.2.0/24) is fully shadowed by rule 1",
+				"rule 3 (allow 0.0.0.0/0) is partially shadowed by rule 1",
+			},
+		},
+	}
+	for i, tc := range testCases {
+		rules, err := ParseRuleLines(tc.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, s := range FindShadowedRules(rules) {
+			got = append(got, s.String())
+		}
+		if diff := gocmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("result mismatch for test case %d, (-want +got):\n%s", i, diff)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package ipacl
+
+import (
+	"math/big"
+	"net/netip"
+)
+
+// SpecialPurpose is a category of special-purpose address space.
+type SpecialPurpose int
+
+const (
+	// Private is the private address space, that is 10.0.0.0/8, 172.16.0.0/12,
+	// 192.168.0.0/16 and fc00::/7.
+	Private SpecialPurpose = iota + 1
+	// Loopback is the loopback address space, that is 127.0.0.0/8 and ::1/128.
+	Loopback
+	// Documentation is the address space for documentation, that is
+	// 192.0.2.0/24, 198.51.100.0/24, 203.0.113.0/24, 2001:db8::/32 and 3fff::/20.
+	Documentation
+)
+
+// String returns the string representation of the special purpose.
+func (p SpecialPurpose) String() string {
+	switch p {
+	case Private:
+		return "private"
+	case Loopback:
+		return "loopback"
+	case Documentation:
+		return "documentation"
+	default:
+		panic("invalid SpecialPurpose")
+	}
+}
+
+type specialPurposePrefixes struct {
+	purpose  SpecialPurpose
+	prefixes []netip.Prefix
+}
+
+var v4SpecialPurposePrefixes = []specialPurposePrefixes{
+	{purpose: Private, prefixes: []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("172.16.0.0/12"),
+		netip.MustParsePrefix("192.168.0.0/16"),
+	}},
+	{purpose: Loopback, prefixes: []netip.Prefix{
+		netip.MustParsePrefix("127.0.0.0/8"),
+	}},
+	{purpose: Documentation, prefixes: []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.0/24"),
+		netip.MustParsePrefix("198.51.100.0/24"),
+		netip.MustParsePrefix("203.0.113.0/24"),
+	}},
+}
+
+var v6SpecialPurposePrefixes = []specialPurposePrefixes{
+	{purpose: Private, prefixes: []netip.Prefix{
+		netip.MustParsePrefix("fc00::/7"),
+	}},
+	{purpose: Loopback, prefixes: []netip.Prefix{
+		netip.MustParsePrefix("::1/128"),
+	}},
+	{purpose: Documentation, prefixes: []netip.Prefix{
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.MustParsePrefix("3fff::/20"),
+	}},
+}
+
+// SpecialPurposeStats is the statistics of a special-purpose address space.
+type SpecialPurposeStats struct {
+	// Purpose is the category of the special-purpose address space.
+	Purpose SpecialPurpose
+	// Allowed is the number of allowed addresses in the space.
+	Allowed *big.Int
+	// Total is the number of addresses in the space.
+	Total *big.Int
+}
+
+// AllowedShare returns the share of allowed addresses in the space
+// between 0 and 1.
+func (s SpecialPurposeStats) AllowedShare() float64 {
+	share, _ := new(big.Rat).SetFrac(s.Allowed, s.Total).Float64()
+	return share
+}
+
+// FamilyStats is the statistics of the compiled ranges for an address family.
+type FamilyStats struct {
+	// Ranges is the number of the ranges.
+	Ranges int
+	// Allowed is the number of allowed addresses.
+	Allowed *big.Int
+	// Denied is the number of denied addresses.
+	Denied *big.Int
+	// LargestAllowed is the largest range of allowed addresses.
+	// It is invalid if no address is allowed.
+	LargestAllowed Range
+	// SpecialPurposes is the statistics of the special-purpose address spaces.
+	SpecialPurposes []SpecialPurposeStats
+}
+
+// Stats is the statistics of the compiled ranges.
+type Stats struct {
+	V4 FamilyStats
+	V6 FamilyStats
+}
+
+// Stats returns the statistics of the compiled ranges.
+func (s *BinarySearch) Stats() Stats {
+	return Stats{
+		V4: newFamilyStatsV4(s.v4RuleRanges(), v4SpecialPurposePrefixes),
+		V6: newFamilyStatsV6(s.v6RuleRanges(), v6SpecialPurposePrefixes),
+	}
+}
@@ -0,0 +1,56 @@
+package ipacl
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	gocmp "github.com/google/go-cmp/cmp"
+)
+
+func formatFamilyStats(st FamilyStats) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ranges=%d, allowed=%s, denied=%s, largest=%s", st.Ranges, st.Allowed, st.Denied, st.LargestAllowed)
+	for _, sp := range st.SpecialPurposes {
+		fmt.Fprintf(&b, ", %s=%s/%s(%.4f)", sp.Purpose, sp.Allowed, sp.Total, sp.AllowedShare())
+	}
+	return b.String()
+}
+
+func TestBinarySearch_Stats(t *testing.T) {
+	testCases := []struct {
+		rules  string
+		wantV4 string
+		wantV6 string
+	}{
+		{
+			rules:  "",
+			wantV4: "ranges=1, allowed=4294967296, denied=0, largest=0.0.0.0-255.255.255.255, private=17891328/17891328(1.0000), loopback=16777216/16777216(1.0000), documentation=768/768(1.0000)",
+			wantV6: "ranges=1, allowed=340282366920938463463374607431768211456, denied=0, largest=::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff, private=2658455991569831745807614120560689152/2658455991569831745807614120560689152(1.0000), loopback=1/1(1.0000), documentation=324597781820940991120749564526592/324597781820940991120749564526592(1.0000)",
+		},
+		{
+			rules:  "allow 10.0.0.0/9\nallow 192.0.2.0/25\nallow 2001:db8::/96\ndeny all",
+			wantV4: "ranges=5, allowed=8388736, denied=4286578560, largest=10.0.0.0-10.127.255.255, private=8388608/17891328(0.4689), loopback=0/16777216(0.0000), documentation=128/768(0.1667)",
+			wantV6: "ranges=3, allowed=4294967296, denied=340282366920938463463374607427473244160, largest=2001:db8::-2001:db8::ffff:ffff, private=0/2658455991569831745807614120560689152(0.0000), loopback=0/1(0.0000), documentation=4294967296/324597781820940991120749564526592(0.0000)",
+		},
+		{
+			rules:  "deny all",
+			wantV4: "ranges=1, allowed=0, denied=4294967296, largest=invalid Range, private=0/17891328(0.0000), loopback=0/16777216(0.0000), documentation=0/768(0.0000)",
+			wantV6: "ranges=1, allowed=0, denied=340282366920938463463374607431768211456, largest=invalid Range, private=0/2658455991569831745807614120560689152(0.0000), loopback=0/1(0.0000), documentation=0/324597781820940991120749564526592(0.0000)",
+		},
+	}
+	for i, tc := range testCases {
+		rules, err := ParseRuleLines(tc.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewBinarySearch(rules)
+		st := s.Stats()
+		if diff := gocmp.Diff(tc.wantV4, formatFamilyStats(st.V4)); diff != "" {
+			t.Errorf("IPv4 stats mismatch for test case %d, (-want +got):\n%s", i, diff)
+		}
+		if diff := gocmp.Diff(tc.wantV6, formatFamilyStats(st.V6)); diff != "" {
+			t.Errorf("IPv6 stats mismatch for test case %d, (-want +got):\n%s", i, diff)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package ipacl
+
+//go:generate sh -c "./gen_rule_range_v6_go.sh"
+
+import (
+	"math/big"
+)
+
+// newFamilyStatsV4 returns the statistics of ranges.
+// ranges must cover the whole address space and be sorted in increasing order.
+func newFamilyStatsV4(ranges []ruleRangeV4, specials []specialPurposePrefixes) FamilyStats {
+	st := FamilyStats{
+		Ranges:  len(ranges),
+		Allowed: new(big.Int),
+		Denied:  new(big.Int),
+	}
+	var largest *big.Int
+	for _, r := range ranges {
+		size := r.ipRange.Size()
+		if r.action == Allow {
+			st.Allowed.Add(st.Allowed, size)
+			if largest == nil || size.Cmp(largest) > 0 {
+				largest = size
+				st.LargestAllowed = r.ipRange.NetIPRange()
+			}
+		} else {
+			st.Denied.Add(st.Denied, size)
+		}
+	}
+
+	for _, special := range specials {
+		sp := SpecialPurposeStats{
+			Purpose: special.purpose,
+			Allowed: new(big.Int),
+			Total:   new(big.Int),
+		}
+		for _, p := range special.prefixes {
+			pr := v4RangeFromPrefix(p)
+			sp.Total.Add(sp.Total, pr.Size())
+			sp.Allowed.Add(sp.Allowed, allowedSizeInRangeV4(ranges, pr))
+		}
+		st.SpecialPurposes = append(st.SpecialPurposes, sp)
+	}
+	return st
+}
+
+// allowedSizeInRangeV4 returns the number of allowed addresses in r.
+// ranges must cover the whole address space and be sorted in increasing order.
+func allowedSizeInRangeV4(ranges []ruleRangeV4, r v4Range) *big.Int {
+	size := new(big.Int)
+	i, _ := binarySearchNoDupFunc(ranges, r.start, func(e ruleRangeV4, t v4Addr) int {
+		return e.ipRange.end.Compare(t)
+	})
+	for ; i < len(ranges) && ranges[i].ipRange.start.Compare(r.end) <= 0; i++ {
+		if ranges[i].action != Allow {
+			continue
+		}
+		overlap := v4Range{
+			start: ranges[i].ipRange.start.Max(r.start),
+			end:   ranges[i].ipRange.end.Min(r.end),
+		}
+		size.Add(size, overlap.Size())
+	}
+	return size
+}
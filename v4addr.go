@@ -3,12 +3,19 @@ package ipacl
 import (
 	"cmp"
 	"encoding/binary"
+	"math/big"
+	"math/bits"
 	"net/netip"
 	"strings"
 )
 
 type v4Addr uint32
 
+// v4AddrBits is the bit length of an IPv4 address.
+const v4AddrBits = 32
+
+const lastV4Addr = v4Addr(0xffff_ffff)
+
 func v4AddrFromBytes(a4 [4]byte) v4Addr {
 	return v4Addr(binary.BigEndian.Uint32(a4[:]))
 }
@@ -25,12 +32,62 @@ func parseV4Addr(s string) (v4Addr, error) {
 	return v4AddrFromBytes(a.As4()), nil
 }
 
+// scanV4Addr parses an IPv4 address in dotted decimal form without
+// allocation. It accepts exactly the same strings as netip.ParseAddr does
+// for IPv4 addresses.
+func scanV4Addr(s string) (v4Addr, bool) {
+	a4, ok := scanV4Bytes(s)
+	if !ok {
+		return v4Addr(0), false
+	}
+	return v4AddrFromBytes(a4), true
+}
+
+func scanV4Bytes(s string) ([4]byte, bool) {
+	var a4 [4]byte
+	var val, pos, digits int
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case '0' <= c && c <= '9':
+			if digits == 1 && val == 0 {
+				// Leading zeros are not allowed.
+				return a4, false
+			}
+			val = val*10 + int(c-'0')
+			digits++
+			if val > 255 {
+				return a4, false
+			}
+		case c == '.':
+			if digits == 0 || i == len(s)-1 || pos == 3 {
+				return a4, false
+			}
+			a4[pos] = byte(val)
+			pos++
+			val, digits = 0, 0
+		default:
+			return a4, false
+		}
+	}
+	if pos < 3 || digits == 0 {
+		return a4, false
+	}
+	a4[3] = byte(val)
+	return a4, true
+}
+
 func (a v4Addr) As4() [4]byte {
 	var b [4]byte
 	binary.BigEndian.PutUint32(b[:], uint32(a))
 	return b
 }
 
+// NetIPAddr returns the address as a netip.Addr.
+func (a v4Addr) NetIPAddr() netip.Addr {
+	return netip.AddrFrom4(a.As4())
+}
+
 func (a v4Addr) String() string {
 	return netip.AddrFrom4(a.As4()).String()
 }
@@ -63,6 +120,23 @@ func (a v4Addr) Next() v4Addr {
 	return v4Addr(uint32(a) + 1)
 }
 
+// LastInPrefix returns the last address in the prefix of a with the
+// specified prefix length.
+func (a v4Addr) LastInPrefix(bits int) v4Addr {
+	return a | (0xffff_ffff >> bits)
+}
+
+// ShortestPrefixLen returns the shortest prefix length of the prefixes
+// which start with a.
+func (a v4Addr) ShortestPrefixLen() int {
+	return v4AddrBits - bits.TrailingZeros32(uint32(a))
+}
+
+// Prefix returns the prefix of a with the specified prefix length.
+func (a v4Addr) Prefix(bits int) netip.Prefix {
+	return netip.PrefixFrom(a.NetIPAddr(), bits).Masked()
+}
+
 type v4Range struct {
 	start v4Addr
 	end   v4Addr
@@ -70,7 +144,7 @@ type v4Range struct {
 
 func v4RangeFromPrefix(p netip.Prefix) v4Range {
 	start := v4AddrFromBytes(p.Masked().Addr().As4())
-	end := start | (0xffff_ffff >> p.Bits())
+	end := start.LastInPrefix(p.Bits())
 	return v4Range{start: start, end: end}
 }
 
@@ -87,6 +161,34 @@ func (r v4Range) Contains(o v4Range) bool {
 	return r.start.Compare(o.start) <= 0 && r.end.Compare(o.end) >= 0
 }
 
+// Size returns the number of addresses in the range.
+func (r v4Range) Size() *big.Int {
+	return new(big.Int).SetUint64(uint64(r.end-r.start) + 1)
+}
+
+// AppendPrefixes appends the minimal list of prefixes which cover the range
+// exactly to prefixes in increasing order.
+func (r v4Range) AppendPrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	start := r.start
+	for {
+		prefixLen := start.ShortestPrefixLen()
+		for start.LastInPrefix(prefixLen).Compare(r.end) > 0 {
+			prefixLen++
+		}
+		prefixes = append(prefixes, start.Prefix(prefixLen))
+		last := start.LastInPrefix(prefixLen)
+		if last.Compare(r.end) >= 0 {
+			return prefixes
+		}
+		start = last.Next()
+	}
+}
+
+// NetIPRange returns the range as a Range.
+func (r v4Range) NetIPRange() Range {
+	return Range{start: r.start.NetIPAddr(), end: r.end.NetIPAddr()}
+}
+
 func (r v4Range) String() string {
 	var b strings.Builder
 	b.WriteString(r.start.String())
@@ -1,6 +1,7 @@
 package ipacl
 
 import (
+	"fmt"
 	"net/netip"
 	"testing"
 )
@@ -49,3 +50,87 @@ func TestV4Range_IsNeighbor(t *testing.T) {
 		t.Errorf("result mismatch, got=%v, want=%v", got, want)
 	}
 }
+
+func TestV4Range_Size(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{input: "192.0.2.1/32", want: "1"},
+		{input: "192.0.2.0/24", want: "256"},
+		{input: "0.0.0.0/0", want: "4294967296"},
+	}
+	for _, tc := range testCases {
+		if got := v4RangeFromPrefix(netip.MustParsePrefix(tc.input)).Size().String(); got != tc.want {
+			t.Errorf("result mismatch, input=%s, got=%s, want=%s", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestV4Range_AppendPrefixes(t *testing.T) {
+	testCases := []struct {
+		start, end string
+		want       string
+	}{
+		{start: "0.0.0.0", end: "255.255.255.255", want: "[0.0.0.0/0]"},
+		{start: "192.0.2.1", end: "192.0.2.1", want: "[192.0.2.1/32]"},
+		{start: "192.0.2.1", end: "192.0.2.6", want: "[192.0.2.1/32 192.0.2.2/31 192.0.2.4/31 192.0.2.6/32]"},
+		{start: "255.255.255.254", end: "255.255.255.255", want: "[255.255.255.254/31]"},
+	}
+	for _, tc := range testCases {
+		r := v4Range{start: mustParseV4Addr(tc.start), end: mustParseV4Addr(tc.end)}
+		if got := fmt.Sprint(r.AppendPrefixes(nil)); got != tc.want {
+			t.Errorf("result mismatch, start=%s, end=%s, got=%s, want=%s", tc.start, tc.end, got, tc.want)
+		}
+	}
+
+	t.Run("exhaustive", func(t *testing.T) {
+		for start := v4Addr(0); start < 64; start++ {
+			for end := start; end < 64; end++ {
+				next := start
+				for _, p := range (v4Range{start: start, end: end}).AppendPrefixes(nil) {
+					pr := v4RangeFromPrefix(p)
+					if pr.start != next || pr.end > end || p != p.Masked() {
+						t.Fatalf("invalid prefix, start=%s, end=%s, prefix=%s", start, end, p)
+					}
+					next = pr.end.Next()
+				}
+				if next != end.Next() {
+					t.Fatalf("prefixes do not cover range, start=%s, end=%s", start, end)
+				}
+			}
+		}
+	})
+}
+
+func TestScanV4Addr(t *testing.T) {
+	testCases := []struct {
+		input string
+		ok    bool
+	}{
+		{input: "0.0.0.0", ok: true},
+		{input: "192.0.2.1", ok: true},
+		{input: "255.255.255.255", ok: true},
+		{input: "256.0.0.0", ok: false},
+		{input: "192.0.2.01", ok: false},
+		{input: "192.0.2", ok: false},
+		{input: "192.0.2.1.1", ok: false},
+		{input: "192.0..1", ok: false},
+		{input: "192.0.2.", ok: false},
+		{input: ".192.0.2", ok: false},
+		{input: "192.0.2.1%eth0", ok: false},
+		{input: "", ok: false},
+	}
+	for _, tc := range testCases {
+		got, ok := scanV4Addr(tc.input)
+		if ok != tc.ok {
+			t.Errorf("ok mismatch, input=%s, got=%v, want=%v", tc.input, ok, tc.ok)
+			continue
+		}
+		if ok {
+			if want := mustParseV4Addr(tc.input); got != want {
+				t.Errorf("result mismatch, input=%s, got=%s, want=%s", tc.input, got, want)
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package ipacl
 import (
 	"cmp"
 	"encoding/binary"
+	"math/big"
 	"math/bits"
 	"net/netip"
 	"strings"
@@ -13,6 +14,11 @@ type v6Addr struct {
 	lo uint64
 }
 
+// v6AddrBits is the bit length of an IPv6 address.
+const v6AddrBits = 128
+
+var lastV6Addr = v6Addr{hi: 0xffff_ffff_ffff_ffff, lo: 0xffff_ffff_ffff_ffff}
+
 func v6AddrFromBytes(a16 [16]byte) v6Addr {
 	return v6Addr{
 		hi: binary.BigEndian.Uint64(a16[:8]),
@@ -32,6 +38,107 @@ func parseV6Addr(s string) (v6Addr, error) {
 	return v6AddrFromBytes(a.As16()), nil
 }
 
+// scanV6Addr parses an IPv6 address without allocation. It accepts exactly
+// the same strings as netip.ParseAddr does for IPv6 addresses. The zone
+// is ignored.
+func scanV6Addr(s string) (v6Addr, bool) {
+	if i := strings.IndexByte(s, '%'); i != -1 {
+		if i == len(s)-1 {
+			return v6Addr{}, false
+		}
+		s = s[:i]
+	}
+
+	var a16 [16]byte
+	ellipsis := -1
+	if len(s) >= 2 && s[0] == ':' && s[1] == ':' {
+		ellipsis = 0
+		s = s[2:]
+		if len(s) == 0 {
+			return v6Addr{}, true
+		}
+	}
+
+	i := 0
+	for i < 16 {
+		off := 0
+		var acc uint32
+	field:
+		for ; off < len(s); off++ {
+			c := s[off]
+			switch {
+			case '0' <= c && c <= '9':
+				acc = acc<<4 + uint32(c-'0')
+			case 'a' <= c && c <= 'f':
+				acc = acc<<4 + uint32(c-'a'+10)
+			case 'A' <= c && c <= 'F':
+				acc = acc<<4 + uint32(c-'A'+10)
+			default:
+				break field
+			}
+			if off > 3 {
+				return v6Addr{}, false
+			}
+		}
+		if off == 0 {
+			return v6Addr{}, false
+		}
+		if off < len(s) && s[off] == '.' {
+			// An embedded IPv4 address must be the last 32 bits.
+			if (ellipsis < 0 && i != 12) || i+4 > 16 {
+				return v6Addr{}, false
+			}
+			a4, ok := scanV4Bytes(s)
+			if !ok {
+				return v6Addr{}, false
+			}
+			copy(a16[i:], a4[:])
+			s = ""
+			i += 4
+			break
+		}
+		a16[i] = byte(acc >> 8)
+		a16[i+1] = byte(acc)
+		i += 2
+		s = s[off:]
+		if len(s) == 0 {
+			break
+		}
+		if s[0] != ':' || len(s) == 1 {
+			return v6Addr{}, false
+		}
+		s = s[1:]
+		if s[0] == ':' {
+			if ellipsis >= 0 {
+				return v6Addr{}, false
+			}
+			ellipsis = i
+			s = s[1:]
+			if len(s) == 0 {
+				break
+			}
+		}
+	}
+	if len(s) != 0 {
+		return v6Addr{}, false
+	}
+
+	if i < 16 {
+		if ellipsis < 0 {
+			return v6Addr{}, false
+		}
+		n := 16 - i
+		for j := i - 1; j >= ellipsis; j-- {
+			a16[j+n] = a16[j]
+		}
+		clear(a16[ellipsis : ellipsis+n])
+	} else if ellipsis >= 0 {
+		// The ellipsis must expand to at least one field of zeros.
+		return v6Addr{}, false
+	}
+	return v6AddrFromBytes(a16), true
+}
+
 func (a v6Addr) As16() [16]byte {
 	var b [16]byte
 	binary.BigEndian.PutUint64(b[:8], uint64(a.hi))
@@ -39,6 +146,11 @@ func (a v6Addr) As16() [16]byte {
 	return b
 }
 
+// NetIPAddr returns the address as a netip.Addr.
+func (a v6Addr) NetIPAddr() netip.Addr {
+	return netip.AddrFrom16(a.As16())
+}
+
 func (a v6Addr) String() string {
 	return netip.AddrFrom16(a.As16()).String()
 }
@@ -85,6 +197,29 @@ func (a v6Addr) Prev() v6Addr {
 	return v6Addr{hi: hi, lo: lo}
 }
 
+// LastInPrefix returns the last address in the prefix of a with the
+// specified prefix length.
+func (a v6Addr) LastInPrefix(bits int) v6Addr {
+	return v6Addr{
+		hi: a.hi | (0xffff_ffff_ffff_ffff >> bits),
+		lo: a.lo | (0xffff_ffff_ffff_ffff >> max(bits-64, 0)),
+	}
+}
+
+// ShortestPrefixLen returns the shortest prefix length of the prefixes
+// which start with a.
+func (a v6Addr) ShortestPrefixLen() int {
+	if a.lo != 0 {
+		return v6AddrBits - bits.TrailingZeros64(a.lo)
+	}
+	return 64 - bits.TrailingZeros64(a.hi)
+}
+
+// Prefix returns the prefix of a with the specified prefix length.
+func (a v6Addr) Prefix(bits int) netip.Prefix {
+	return netip.PrefixFrom(a.NetIPAddr(), bits).Masked()
+}
+
 type v6Range struct {
 	start v6Addr
 	end   v6Addr
@@ -92,9 +227,7 @@ type v6Range struct {
 
 func v6RangeFromPrefix(p netip.Prefix) v6Range {
 	start := v6AddrFromBytes(p.Masked().Addr().As16())
-	var end v6Addr
-	end.hi = start.hi | (0xffff_ffff_ffff_ffff >> p.Bits())
-	end.lo = start.lo | (0xffff_ffff_ffff_ffff >> max(p.Bits()-64, 0))
+	end := start.LastInPrefix(p.Bits())
 	return v6Range{start: start, end: end}
 }
 
@@ -111,6 +244,39 @@ func (r v6Range) Contains(o v6Range) bool {
 	return r.start.Compare(o.start) <= 0 && r.end.Compare(o.end) >= 0
 }
 
+// Size returns the number of addresses in the range.
+func (r v6Range) Size() *big.Int {
+	lo, borrow := bits.Sub64(r.end.lo, r.start.lo, 0)
+	hi, _ := bits.Sub64(r.end.hi, r.start.hi, borrow)
+	n := new(big.Int).SetUint64(hi)
+	n.Lsh(n, 64)
+	n.Or(n, new(big.Int).SetUint64(lo))
+	return n.Add(n, big.NewInt(1))
+}
+
+// AppendPrefixes appends the minimal list of prefixes which cover the range
+// exactly to prefixes in increasing order.
+func (r v6Range) AppendPrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	start := r.start
+	for {
+		prefixLen := start.ShortestPrefixLen()
+		for start.LastInPrefix(prefixLen).Compare(r.end) > 0 {
+			prefixLen++
+		}
+		prefixes = append(prefixes, start.Prefix(prefixLen))
+		last := start.LastInPrefix(prefixLen)
+		if last.Compare(r.end) >= 0 {
+			return prefixes
+		}
+		start = last.Next()
+	}
+}
+
+// NetIPRange returns the range as a Range.
+func (r v6Range) NetIPRange() Range {
+	return Range{start: r.start.NetIPAddr(), end: r.end.NetIPAddr()}
+}
+
 func (r v6Range) String() string {
 	var b strings.Builder
 	b.WriteString(r.start.String())
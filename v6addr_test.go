@@ -1,6 +1,7 @@
 package ipacl
 
 import (
+	"fmt"
 	"net/netip"
 	"testing"
 )
@@ -50,3 +51,78 @@ func TestV6Addr_Max(t *testing.T) {
 		t.Errorf("result mismatch, got=%s, want=%s", got, want)
 	}
 }
+
+func TestV6Range_Size(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{input: "2001:db8::1/128", want: "1"},
+		{input: "2001:db8::/64", want: "18446744073709551616"},
+		{input: "2001:db8::/63", want: "36893488147419103232"},
+		{input: "::/0", want: "340282366920938463463374607431768211456"},
+	}
+	for _, tc := range testCases {
+		if got := v6RangeFromPrefix(netip.MustParsePrefix(tc.input)).Size().String(); got != tc.want {
+			t.Errorf("result mismatch, input=%s, got=%s, want=%s", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestV6Range_AppendPrefixes(t *testing.T) {
+	testCases := []struct {
+		start, end string
+		want       string
+	}{
+		{start: "::", end: "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", want: "[::/0]"},
+		{start: "2001:db8::1", end: "2001:db8::1", want: "[2001:db8::1/128]"},
+		{start: "::ffff:ffff:ffff:ffff", end: "0:0:0:1::1", want: "[::ffff:ffff:ffff:ffff/128 0:0:0:1::/127]"},
+		{start: "2001:db8::", end: "2001:db9:ffff:ffff:ffff:ffff:ffff:ffff", want: "[2001:db8::/31]"},
+		{start: "8000::", end: "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", want: "[8000::/1]"},
+	}
+	for _, tc := range testCases {
+		r := v6Range{start: mustParseV6Addr(tc.start), end: mustParseV6Addr(tc.end)}
+		if got := fmt.Sprint(r.AppendPrefixes(nil)); got != tc.want {
+			t.Errorf("result mismatch, start=%s, end=%s, got=%s, want=%s", tc.start, tc.end, got, tc.want)
+		}
+	}
+}
+
+func TestScanV6Addr(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{input: "::", want: "::"},
+		{input: "2001:db8::1", want: "2001:db8::1"},
+		{input: "2001:DB8::1", want: "2001:db8::1"},
+		{input: "::ffff:192.0.2.1", want: "::ffff:192.0.2.1"},
+		{input: "1:2:3:4:5:6:192.0.2.1", want: "1:2:3:4:5:6:c000:201"},
+		{input: "fe80::1%eth0", want: "fe80::1"},
+		{input: "1:2:3:4:5:6:7:8", want: "1:2:3:4:5:6:7:8"},
+		{input: "1:2:3:4:5:6:7::", want: "1:2:3:4:5:6:7:0"},
+		{input: "fe80::1%", want: ""},
+		{input: "1:2:3:4:5:6:7:8:9", want: ""},
+		{input: "1:2:3:4:5:6:7", want: ""},
+		{input: "1:2:3:4::5:6:7:8", want: ""},
+		{input: "1::2::3", want: ""},
+		{input: "12345::", want: ""},
+		{input: "2001:db8:", want: ""},
+		{input: ":2001:db8::", want: ""},
+		{input: "1:2:3:4:5:192.0.2.1", want: ""},
+		{input: "::ffff:192.0.2.01", want: ""},
+		{input: "::g", want: ""},
+	}
+	for _, tc := range testCases {
+		a, ok := scanV6Addr(tc.input)
+		if got, want := ok, tc.want != ""; got != want {
+			t.Errorf("ok mismatch, input=%s, got=%v, want=%v", tc.input, got, want)
+			continue
+		}
+		if ok {
+			if got := a.String(); got != tc.want {
+				t.Errorf("result mismatch, input=%s, got=%s, want=%s", tc.input, got, tc.want)
+			}
+		}
+	}
+}